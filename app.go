@@ -10,6 +10,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -19,6 +20,9 @@ type App struct {
 	projectPath string
 	cliPath     string // path to carmelia CLI source
 	projectRoot string // root of the carmelia project (where package.json lives)
+
+	runMu     sync.Mutex
+	cancelRun context.CancelFunc // cancels the collection or data file run in progress
 }
 
 func NewApp() *App {
@@ -261,88 +265,372 @@ func (a *App) ParseRequest(content string) models.ParsedHttpRequest {
 	return services.ParseHttpFile(content)
 }
 
+// ParseRequests parses every request of a multi-request .http file
+func (a *App) ParseRequests(content string) []models.ParsedHttpRequest {
+	return services.ParseHttpFileAll(content)
+}
+
 // ExecuteRequest parses, resolves variables, and executes an HTTP request.
 // historyKey is used to group history entries (typically the file's relative path).
 func (a *App) ExecuteRequest(content string, envName string, projectPath string, sets map[string]string, historyKey string) (models.RunResult, error) {
-	parsed := services.ParseHttpFile(content)
+	return a.ExecuteRequestTarget(content, "", envName, projectPath, sets, historyKey)
+}
+
+// ExecuteRequestTarget executes one request of a multi-request .http file.
+// target is the request index or name; empty selects the first request.
+func (a *App) ExecuteRequestTarget(content string, target string, envName string, projectPath string, sets map[string]string, historyKey string) (models.RunResult, error) {
+	requests, parsed, err := loadRequest(content, target)
+	if err != nil {
+		return models.RunResult{}, err
+	}
 
 	effectivePath := projectPath
 	if effectivePath == "" {
 		effectivePath = a.projectPath
 	}
 
-	// Load env variables
+	// Use historyKey for grouping history; fall back to content hash if empty
+	fileKey := historyKey
+	if fileKey == "" {
+		fileKey = content
+	}
+
+	if sets == nil {
+		sets = map[string]string{}
+	}
+
+	run := services.RequestRun{
+		ProjectPath: effectivePath,
+		EnvName:     envName,
+		File:        requests,
+		FileKey:     fileKey,
+	}
+	hKey := services.RequestHistoryKey(fileKey, parsed, len(requests))
+	return services.RunRequest(run, parsed, hKey, sets), nil
+}
+
+// loadRequest parses a .http file and selects the request named or indexed
+// by target. An empty target selects the first request, or a file without
+// any request line as a whole.
+func loadRequest(content string, target string) ([]models.ParsedHttpRequest, models.ParsedHttpRequest, error) {
+	requests := services.ParseHttpFileAll(content)
+	parsed, err := services.SelectRequest(requests, target)
+	if err != nil {
+		if target != "" {
+			return nil, models.ParsedHttpRequest{}, err
+		}
+		parsed = services.ParseHttpFile(content)
+	}
+	return requests, parsed, nil
+}
+
+// PreviewRequest resolves one request of a .http file without sending it
+// and reports where each placeholder's value came from, with secrets
+// masked. Named requests referenced by the request are served from history
+// only; nothing is executed, so variables its scripts set are left as
+// written. dataFile, when set, provides the first row's columns.
+func (a *App) PreviewRequest(content string, target string, envName string, projectPath string, sets map[string]string, historyKey string, dataFile string) (models.ResolvePreview, error) {
+	requests, parsed, err := loadRequest(content, target)
+	if err != nil {
+		return models.ResolvePreview{}, err
+	}
+
+	if projectPath == "" {
+		projectPath = a.projectPath
+	}
+	fileKey := historyKey
+	if fileKey == "" {
+		fileKey = content
+	}
+
 	env := models.EnvVariables{}
-	if envName != "" && effectivePath != "" {
-		var err error
-		env, err = services.LoadEnv(effectivePath, envName)
+	if envName != "" && projectPath != "" {
+		if loaded, err := services.LoadEnv(projectPath, envName); err == nil {
+			env = loaded
+		}
+	}
+
+	var data map[string]string
+	if dataFile != "" {
+		rows, err := services.LoadDataFile(projectPath, dataFile)
 		if err != nil {
-			env = models.EnvVariables{}
+			return models.ResolvePreview{}, err
+		}
+		data = rows[0]
+	}
+
+	var visiting []string
+	if parsed.Name != "" {
+		visiting = []string{parsed.Name}
+	}
+	preview := services.PreviewRequest(parsed, services.ResolveOptions{
+		Env:         env,
+		Sets:        sets,
+		Data:        data,
+		Runtime:     services.RuntimeValues(projectPath, envName),
+		Responses:   services.NamedResponseLookup(projectPath, requests, fileKey, visiting, nil),
+		Pending:     services.ScriptSetNames(parsed.Scripts, services.ScriptDir(projectPath, fileKey)),
+		ProjectPath: projectPath,
+	})
+	preview.Env = envName
+	return preview, nil
+}
+
+// RunCollection runs every request under a folder of .carmelia/requests/
+// (empty for all of them) against an environment. dataFile, when set, is a
+// CSV or JSON file (relative to the project root) whose rows each run the
+// whole folder once. Progress is emitted as "collection:progress" events
+// after each request.
+func (a *App) RunCollection(projectPath string, folder string, envName string, dataFile string) (models.CollectionReport, error) {
+	if projectPath == "" {
+		projectPath = a.projectPath
+	}
+	if projectPath == "" {
+		return models.CollectionReport{}, fmt.Errorf("no project selected")
+	}
+
+	var data []map[string]string
+	if dataFile != "" {
+		var err error
+		if data, err = services.LoadDataFile(projectPath, dataFile); err != nil {
+			return models.CollectionReport{}, err
 		}
 	}
 
+	ctx, done, err := a.startRun()
+	if err != nil {
+		return models.CollectionReport{}, err
+	}
+	defer done()
+
+	report, err := services.RunCollection(ctx, projectPath, folder, envName, data, a.emitRunProgress)
+	report.DataFile = dataFile
+	return report, err
+}
+
+// ExecuteRequestData runs one request of a .http file once per row of a
+// CSV or JSON data file (relative to the project root), with the row's
+// columns as variables. Results are reported like a collection run and
+// progress is emitted as "collection:progress" events.
+func (a *App) ExecuteRequestData(content string, target string, envName string, projectPath string, sets map[string]string, historyKey string, dataFile string) (models.CollectionReport, error) {
+	requests, parsed, err := loadRequest(content, target)
+	if err != nil {
+		return models.CollectionReport{}, err
+	}
+
+	if projectPath == "" {
+		projectPath = a.projectPath
+	}
+	if projectPath == "" {
+		return models.CollectionReport{}, fmt.Errorf("no project selected")
+	}
+	data, err := services.LoadDataFile(projectPath, dataFile)
+	if err != nil {
+		return models.CollectionReport{}, err
+	}
+
+	fileKey := historyKey
+	if fileKey == "" {
+		fileKey = content
+	}
+	if sets == nil {
+		sets = map[string]string{}
+	}
+
+	ctx, done, err := a.startRun()
+	if err != nil {
+		return models.CollectionReport{}, err
+	}
+	defer done()
+
+	run := services.RequestRun{
+		ProjectPath: projectPath,
+		EnvName:     envName,
+		File:        requests,
+		FileKey:     fileKey,
+	}
+	hKey := services.RequestHistoryKey(fileKey, parsed, len(requests))
+	report := services.RunRequestIterations(ctx, run, parsed, hKey, sets, data, a.emitRunProgress)
+	report.DataFile = dataFile
+	return report, nil
+}
+
+// startRun registers a cancellable run; only one may be in progress. done
+// must be called when the run ends.
+func (a *App) startRun() (context.Context, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	if a.cancelRun != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("another run is already in progress")
+	}
+	a.cancelRun = cancel
+
+	return ctx, func() {
+		a.runMu.Lock()
+		a.cancelRun = nil
+		a.runMu.Unlock()
+		cancel()
+	}, nil
+}
+
+func (a *App) emitRunProgress(p models.CollectionProgress) {
+	runtime.EventsEmit(a.ctx, "collection:progress", p)
+}
+
+// CancelCollectionRun stops the collection or data file run in progress
+// after its current request
+func (a *App) CancelCollectionRun() {
+	a.runMu.Lock()
+	defer a.runMu.Unlock()
+	if a.cancelRun != nil {
+		a.cancelRun()
+	}
+}
+
+// RunLoadTest sends one request of a .http file repeatedly as set by
+// config and returns the final stats. Live stats are emitted as
+// "loadtest:progress" events.
+func (a *App) RunLoadTest(content string, target string, envName string, projectPath string, sets map[string]string, historyKey string, config models.LoadTestConfig) (models.LoadTestReport, error) {
+	requests, parsed, err := loadRequest(content, target)
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+
+	if projectPath == "" {
+		projectPath = a.projectPath
+	}
+	fileKey := historyKey
+	if fileKey == "" {
+		fileKey = content
+	}
 	if sets == nil {
 		sets = map[string]string{}
 	}
 
-	// Resolve variables
-	resolved := services.ResolveRequest(parsed, services.ResolveOptions{
-		Env:  env,
-		Sets: sets,
+	ctx, done, err := a.startRun()
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+	defer done()
+
+	run := services.RequestRun{
+		ProjectPath: projectPath,
+		EnvName:     envName,
+		File:        requests,
+		FileKey:     fileKey,
+	}
+	return services.RunLoadTest(ctx, run, parsed, sets, config, func(s models.LoadTestStats) {
+		runtime.EventsEmit(a.ctx, "loadtest:progress", s)
 	})
+}
+
+// RunFolderLoadTest load tests every request under a folder of
+// .carmelia/requests/ (empty for all of them), sending them in turn. Live
+// stats are emitted as "loadtest:progress" events.
+func (a *App) RunFolderLoadTest(projectPath string, folder string, envName string, config models.LoadTestConfig) (models.LoadTestReport, error) {
+	if projectPath == "" {
+		projectPath = a.projectPath
+	}
+	if projectPath == "" {
+		return models.LoadTestReport{}, fmt.Errorf("no project selected")
+	}
+
+	ctx, done, err := a.startRun()
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+	defer done()
 
-	// Load config for timeout/redirect settings
-	config, _ := services.LoadConfig(effectivePath)
-
-	// Execute request
-	resp, err := services.ExecuteRequest(services.ExecuteOptions{
-		Method:          resolved.Method,
-		URL:             resolved.URL,
-		Headers:         resolved.Headers,
-		Body:            resolved.Body,
-		Timeout:         config.Runner.Timeout,
-		FollowRedirects: config.Runner.FollowRedirects,
+	return services.RunFolderLoadTest(ctx, projectPath, folder, envName, config, func(s models.LoadTestStats) {
+		runtime.EventsEmit(a.ctx, "loadtest:progress", s)
 	})
+}
 
-	// Use historyKey for grouping history; fall back to content hash if empty
-	hKey := historyKey
-	if hKey == "" {
-		hKey = content
+// CancelLoadTest stops the load test in progress. Load tests share their
+// slot with collection runs, so this is CancelCollectionRun by another name.
+func (a *App) CancelLoadTest() {
+	a.CancelCollectionRun()
+}
+
+// SaveLoadTestReport saves a load test report as JSON next to the request
+// file (relative to .carmelia/requests/) it was run from, or in the folder
+// of a folder test, and returns the report's path.
+func (a *App) SaveLoadTestReport(projectPath string, requestPath string, report models.LoadTestReport) (string, error) {
+	if projectPath == "" {
+		projectPath = a.projectPath
 	}
+	return services.SaveLoadTestReport(projectPath, requestPath, report)
+}
 
+// ExportCollectionReport saves a collection run report as json, junit or
+// html, asking where to write it
+func (a *App) ExportCollectionReport(report models.CollectionReport, format string) (string, error) {
+	content, err := services.FormatCollectionReport(report, format)
 	if err != nil {
-		result := models.RunResult{
-			Request: resolved,
-			Error:   err.Error(),
-		}
-		// Save to history even on error
-		go services.SaveHistoryEntry(effectivePath, hKey, config.Runner.MaxHistory, result)
-		return result, nil
+		return "", err
+	}
+
+	displayName, pattern := services.ReportFileFilter(format)
+	savePath, err := runtime.SaveFileDialog(a.ctx, runtime.SaveDialogOptions{
+		Title:           "Export Report",
+		DefaultFilename: services.ReportFilename(report, format),
+		Filters: []runtime.FileFilter{
+			{DisplayName: displayName, Pattern: pattern},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if savePath == "" {
+		return "", nil // user cancelled
 	}
 
-	result := models.RunResult{
-		Request:  resolved,
-		Response: resp,
+	if err := os.WriteFile(savePath, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write report: %w", err)
 	}
-	// Auto-save to history
-	go services.SaveHistoryEntry(effectivePath, hKey, config.Runner.MaxHistory, result)
-	return result, nil
+	return savePath, nil
 }
 
-// GetHistory returns the history entries for a request
-func (a *App) GetHistory(projectPath string, requestContent string) ([]models.HistoryEntry, error) {
+// GetHistory returns the history entries for one request of a .http file.
+// content, target and historyKey select the request as for
+// ExecuteRequestTarget.
+func (a *App) GetHistory(projectPath string, content string, target string, historyKey string) ([]models.HistoryEntry, error) {
 	if projectPath == "" {
 		projectPath = a.projectPath
 	}
-	return services.LoadHistory(projectPath, requestContent)
+	hKey, err := requestHistoryKey(content, target, historyKey)
+	if err != nil {
+		return nil, err
+	}
+	return services.LoadHistory(projectPath, hKey)
 }
 
-// ClearHistory clears the history for a request
-func (a *App) ClearHistory(projectPath string, requestContent string) error {
+// ClearHistory clears the history for one request of a .http file
+func (a *App) ClearHistory(projectPath string, content string, target string, historyKey string) error {
 	if projectPath == "" {
 		projectPath = a.projectPath
 	}
-	return services.ClearHistory(projectPath, requestContent)
+	hKey, err := requestHistoryKey(content, target, historyKey)
+	if err != nil {
+		return err
+	}
+	return services.ClearHistory(projectPath, hKey)
+}
+
+// requestHistoryKey returns the key history is saved under for the request
+// target selects in content.
+func requestHistoryKey(content string, target string, historyKey string) (string, error) {
+	requests, parsed, err := loadRequest(content, target)
+	if err != nil {
+		return "", err
+	}
+	fileKey := historyKey
+	if fileKey == "" {
+		fileKey = content
+	}
+	return services.RequestHistoryKey(fileKey, parsed, len(requests)), nil
 }
 
 // ListEnvsForProject returns available environment names for a specific project
@@ -381,6 +669,87 @@ func (a *App) SaveEnvForProject(projectPath string, name string, vars models.Env
 	return services.SaveEnv(projectPath, name, vars)
 }
 
+// CompareEnvs compares the environments of a project: keys missing from
+// some of them, unset ${VAR} references, and request variables that no
+// environment defines. The columns of dataFiles (relative to the project
+// root) count as defined
+func (a *App) CompareEnvs(projectPath string, dataFiles []string) (models.EnvComparison, error) {
+	return services.CompareEnvs(projectPath, dataFiles)
+}
+
+// ListRuntimeValues returns the values captured at run time for an
+// environment, with when and by which request each was set
+func (a *App) ListRuntimeValues(projectPath, envName string) (models.RuntimeVariables, error) {
+	return services.LoadRuntime(projectPath, envName)
+}
+
+// SetRuntimeValue edits one runtime value of an environment
+func (a *App) SetRuntimeValue(projectPath, envName, name, value string) error {
+	return services.SetRuntimeValues(projectPath, envName, map[string]string{name: value}, "")
+}
+
+// DeleteRuntimeValue removes one runtime value of an environment
+func (a *App) DeleteRuntimeValue(projectPath, envName, name string) error {
+	return services.DeleteRuntimeValue(projectPath, envName, name)
+}
+
+// ClearRuntimeValues removes every runtime value of an environment
+func (a *App) ClearRuntimeValues(projectPath, envName string) error {
+	return services.ClearRuntime(projectPath, envName)
+}
+
+// ListCookies returns the cookies in an environment's cookie jar
+func (a *App) ListCookies(projectPath, envName string) ([]models.JarCookie, error) {
+	return services.ListCookies(projectPath, envName)
+}
+
+// SetCookie adds a cookie to an environment's cookie jar, or edits the one
+// with the same domain, path and name
+func (a *App) SetCookie(projectPath, envName string, cookie models.JarCookie) error {
+	return services.SetCookie(projectPath, envName, cookie)
+}
+
+// DeleteCookie removes one cookie from an environment's cookie jar
+func (a *App) DeleteCookie(projectPath, envName, domain, path, name string) error {
+	return services.DeleteCookie(projectPath, envName, domain, path, name)
+}
+
+// ClearCookies empties an environment's cookie jar
+func (a *App) ClearCookies(projectPath, envName string) error {
+	return services.ClearCookies(projectPath, envName)
+}
+
+// UnlockSecrets decrypts an environment's secrets file with a passphrase or
+// key file. Its values are then merged into the environment until locked.
+func (a *App) UnlockSecrets(projectPath string, envName string, cred models.SecretsCredential) error {
+	return services.UnlockSecrets(projectPath, envName, cred)
+}
+
+// LockSecrets forgets the decrypted secrets of an environment
+func (a *App) LockSecrets(projectPath string, envName string) {
+	services.LockSecrets(projectPath, envName)
+}
+
+// RotateSecrets re-encrypts an unlocked secrets file with a new credential
+func (a *App) RotateSecrets(projectPath string, envName string, cred models.SecretsCredential) error {
+	return services.RotateSecrets(projectPath, envName, cred)
+}
+
+// GetSecrets returns the values of an unlocked secrets file
+func (a *App) GetSecrets(projectPath string, envName string) (map[string]string, error) {
+	return services.GetSecrets(projectPath, envName)
+}
+
+// SaveSecrets replaces the values of an unlocked secrets file
+func (a *App) SaveSecrets(projectPath string, envName string, values map[string]string) error {
+	return services.SaveSecrets(projectPath, envName, values)
+}
+
+// GetSecretsStatus reports whether an environment has secrets and whether they are unlocked
+func (a *App) GetSecretsStatus(projectPath string, envName string) models.SecretsStatus {
+	return services.SecretsStatusFor(projectPath, envName)
+}
+
 // SaveOpenProjects persists the current session state (open project paths + active index)
 func (a *App) SaveOpenProjects(state models.SessionState) error {
 	return services.SaveSessionState(state)
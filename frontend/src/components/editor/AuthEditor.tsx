@@ -1,5 +1,6 @@
 import { useEffect, useState, useRef } from 'react'
-import { useAppStore, type AuthConfig, type AuthType } from '../../store/appStore'
+import { useAppStore, type AuthConfig, type AuthType, type Header } from '../../store/appStore'
+import { getHeader, withoutHeader } from '../../utils/headers'
 import { rebuildFromParsed } from '../../utils/httpRebuilder'
 import { ParseRequest } from '../../../wailsjs/go/main/App'
 
@@ -80,8 +81,8 @@ function Dropdown<T extends string>({
   )
 }
 
-function detectAuthFromHeaders(headers: Record<string, string>): AuthConfig | null {
-  const auth = headers['Authorization'] || headers['authorization']
+function detectAuthFromHeaders(headers: Header[]): AuthConfig | null {
+  const auth = getHeader(headers, 'Authorization')
   if (!auth) return null
 
   if (auth.startsWith('Bearer ')) {
@@ -126,19 +127,17 @@ export function AuthEditor() {
     const currentParsed = s.parsed
     if (!currentParsed) return
 
-    const newHeaders = { ...currentParsed.headers }
-
     // Remove existing auth headers
-    delete newHeaders['Authorization']
-    delete newHeaders['authorization']
+    let newHeaders = withoutHeader(currentParsed.headers, 'Authorization')
 
     if (config.type === 'bearer' && config.bearer?.token) {
-      newHeaders['Authorization'] = `Bearer ${config.bearer.token}`
+      newHeaders.push({ name: 'Authorization', value: `Bearer ${config.bearer.token}` })
     } else if (config.type === 'basic' && config.basic) {
       const encoded = btoa(`${config.basic.username}:${config.basic.password}`)
-      newHeaders['Authorization'] = `Basic ${encoded}`
+      newHeaders.push({ name: 'Authorization', value: `Basic ${encoded}` })
     } else if (config.type === 'apikey' && config.apikey?.addTo === 'header' && config.apikey.key) {
-      newHeaders[config.apikey.key] = config.apikey.value
+      newHeaders = withoutHeader(newHeaders, config.apikey.key)
+      newHeaders.push({ name: config.apikey.key, value: config.apikey.value })
     }
 
     // For API Key added to query, we handle it in the URL
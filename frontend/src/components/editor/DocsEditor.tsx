@@ -1,5 +1,6 @@
 import { useState, useEffect, useRef } from 'react'
 import { useAppStore, type ParamDoc } from '../../store/appStore'
+import { rebuildFromParsed } from '../../utils/httpRebuilder'
 import { ParseRequest } from '../../../wailsjs/go/main/App'
 
 const LOCATION_OPTIONS = [
@@ -93,21 +94,7 @@ export function DocsEditor() {
     const allComments = [...docComments, ...nonDocComments]
 
     // Rebuild the raw content
-    const lines: string[] = []
-    for (const comment of allComments) {
-      lines.push(`# ${comment}`)
-    }
-    if (allComments.length > 0) lines.push('')
-    lines.push(`${currentParsed.method} ${currentParsed.url}`)
-    for (const [key, value] of Object.entries(currentParsed.headers)) {
-      lines.push(`${key}: ${value}`)
-    }
-    if (currentParsed.body) {
-      lines.push('')
-      lines.push(currentParsed.body)
-    }
-
-    const rebuilt = lines.join('\n')
+    const rebuilt = rebuildFromParsed(currentParsed, { comments: allComments })
     s.setRawContent(rebuilt)
     const reParsed = await ParseRequest(rebuilt)
     s.setParsed(reParsed)
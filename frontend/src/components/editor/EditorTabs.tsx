@@ -26,7 +26,7 @@ export function EditorTabs() {
   const activeProject = useAppStore((s) => getActiveProject(s))
   const activeInstanceId = useAppStore((s) => s.openTabs[s.activeTabIndex]?.instanceId)
 
-  const headerCount = parsed ? Object.keys(parsed.headers).length : 0
+  const headerCount = parsed ? parsed.headers.length : 0
 
   // Count query params
   const paramCount = parsed?.url ? (parsed.url.split('?')[1] || '').split('&').filter((p) => p).length : 0
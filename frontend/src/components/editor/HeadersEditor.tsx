@@ -1,5 +1,5 @@
 import { useState, useEffect, useRef } from 'react'
-import { useAppStore } from '../../store/appStore'
+import { useAppStore, type Header } from '../../store/appStore'
 import { rebuildFromParsed } from '../../utils/httpRebuilder'
 
 export function HeadersEditor() {
@@ -13,17 +13,17 @@ export function HeadersEditor() {
       return
     }
     if (parsed) {
-      setEntries(Object.entries(parsed.headers))
+      setEntries(parsed.headers.map((h): [string, string] => [h.name, h.value]))
     }
   }, [parsed?.headers])
 
   if (!parsed) return null
 
   const applyChanges = (newEntries: [string, string][]) => {
-    const headers: Record<string, string> = {}
+    const headers: Header[] = []
     for (const [key, value] of newEntries) {
       if (key.trim()) {
-        headers[key.trim()] = value
+        headers.push({ name: key.trim(), value })
       }
     }
     const s = useAppStore.getState()
@@ -26,7 +26,7 @@ export function HistoryPanel() {
     if (!activeProject || !activeFile) return
     try {
       const { GetHistory } = await import('../../../wailsjs/go/main/App')
-      const entries = await GetHistory(activeProject.path, activeFile)
+      const entries = await GetHistory(activeProject.path, useAppStore.getState().rawContent, '', activeFile)
       setHistoryEntries(entries || [])
     } catch {
       setHistoryEntries([])
@@ -39,6 +39,7 @@ export function HistoryPanel() {
       request: entry.request,
       response: entry.response,
       error: entry.error,
+      assertions: entry.assertions,
     })
   }
 
@@ -53,7 +54,7 @@ export function HistoryPanel() {
     if (!activeProject || !activeFile) return
     try {
       const { ClearHistory } = await import('../../../wailsjs/go/main/App')
-      await ClearHistory(activeProject.path, activeFile)
+      await ClearHistory(activeProject.path, useAppStore.getState().rawContent, '', activeFile)
       setHistoryEntries([])
       setSelectedHistoryEntry(null)
     } catch { /* ignore */ }
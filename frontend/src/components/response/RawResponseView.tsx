@@ -11,7 +11,7 @@ export function RawResponseView() {
 
   const raw = [
     statusText,
-    ...Object.entries(headers).map(([k, v]) => `${k}: ${v}`),
+    ...(headers || []).map(({ name, value }) => `${name}: ${value}`),
     '',
     body,
   ].join('\n')
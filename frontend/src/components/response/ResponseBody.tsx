@@ -7,6 +7,7 @@ import { oneDarkHighlightStyle } from '@codemirror/theme-one-dark'
 import { syntaxHighlighting } from '@codemirror/language'
 import { basicSetup } from 'codemirror'
 import { useAppStore } from '../../store/appStore'
+import { getHeader } from '../../utils/headers'
 
 export function ResponseBody() {
   const result = useAppStore((s) => s.response)
@@ -15,7 +16,7 @@ export function ResponseBody() {
   const [viewMode, setViewMode] = useState<'preview' | 'source'>('preview')
 
   const body = result?.response?.body || ''
-  const contentType = result?.response?.headers?.['Content-Type'] || ''
+  const contentType = getHeader(result?.response?.headers, 'Content-Type')
   const isHtml = contentType.includes('text/html')
 
   // Try to format JSON
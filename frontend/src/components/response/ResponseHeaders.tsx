@@ -9,7 +9,7 @@ export function ResponseHeaders() {
     )
   }
 
-  const headers = Object.entries(result.response.headers)
+  const headers = result.response.headers
 
   return (
     <div className="overflow-auto h-full">
@@ -21,9 +21,9 @@ export function ResponseHeaders() {
           </tr>
         </thead>
         <tbody>
-          {headers.map(([key, value]) => (
-            <tr key={key} className="border-b border-gray-800 hover:bg-gray-800/50">
-              <td className="px-3 py-2 text-gray-300 font-mono">{key}</td>
+          {headers.map(({ name, value }, i) => (
+            <tr key={i} className="border-b border-gray-800 hover:bg-gray-800/50">
+              <td className="px-3 py-2 text-gray-300 font-mono">{name}</td>
               <td className="px-3 py-2 text-gray-400 font-mono break-all">{value}</td>
             </tr>
           ))}
@@ -38,6 +38,11 @@ export function ResponseMeta() {
   if (!result?.response) return null
 
   const { status, statusText, time, size } = result.response
+  const unresolved = result.unresolved || []
+  const failedCaptures = (result.captures || []).filter((c) => c.error)
+  const assertions = result.assertions || []
+  const failedAssertions = assertions.filter((a) => !a.passed)
+  const failedScripts = (result.scripts || []).filter((s) => s.error)
 
   return (
     <div className="flex items-center gap-3 px-3 py-2 border-b border-gray-700 bg-gray-800/50">
@@ -50,6 +55,38 @@ export function ResponseMeta() {
       <span className="text-xs text-gray-500">
         {formatSize(size)}
       </span>
+      {unresolved.length > 0 && (
+        <span
+          className="text-xs text-yellow-400"
+          title={unresolved.map((u) => `${u.name} (${u.chain ? u.chain.join(' → ') : u.reason}${u.field ? `, ${u.field}` : ''})`).join('\n')}
+        >
+          {unresolved.length} unresolved variable{unresolved.length > 1 ? 's' : ''}
+        </span>
+      )}
+      {failedCaptures.length > 0 && (
+        <span
+          className="text-xs text-red-400"
+          title={failedCaptures.map((c) => `${c.name}: ${c.error}`).join('\n')}
+        >
+          {failedCaptures.length} failed capture{failedCaptures.length > 1 ? 's' : ''}
+        </span>
+      )}
+      {assertions.length > 0 && (
+        <span
+          className={`text-xs ${failedAssertions.length > 0 ? 'text-red-400' : 'text-green-400'}`}
+          title={assertions.map((a) => `${a.passed ? '✓' : '✗'} ${a.expr}${a.error ? ` — ${a.error}` : !a.passed ? ` — got ${a.actual ?? 'nothing'}` : ''}`).join('\n')}
+        >
+          {assertions.length - failedAssertions.length}/{assertions.length} assertions passed
+        </span>
+      )}
+      {failedScripts.length > 0 && (
+        <span
+          className="text-xs text-red-400"
+          title={failedScripts.map((s) => `@${s.phase} ${s.source}: ${s.error}`).join('\n')}
+        >
+          {failedScripts.length} script error{failedScripts.length > 1 ? 's' : ''}
+        </span>
+      )}
     </div>
   )
 }
@@ -24,7 +24,7 @@ function reloadHistory() {
   // Small delay to let the Go goroutine finish writing history
   setTimeout(async () => {
     try {
-      const entries = await GetHistory(project.path, s.activeFile!)
+      const entries = await GetHistory(project.path, s.rawContent, '', s.activeFile!)
       useAppStore.getState().setHistoryEntries(entries || [])
     } catch { /* ignore */ }
   }, 200)
@@ -69,7 +69,7 @@ export function ResponsePanel() {
       const project = getActiveProject(s)
       if (project && s.activeFile) {
         try {
-          const entries = await GetHistory(project.path, s.activeFile)
+          const entries = await GetHistory(project.path, s.rawContent, '', s.activeFile)
           s.setHistoryEntries(entries || [])
           // entries[0] is the current response (just saved), so previous = entries[1]
           if (entries && entries.length > 1) {
@@ -20,29 +20,262 @@ export interface RequestDocs {
   params?: ParamDoc[]
 }
 
+export interface Header {
+  name: string
+  value: string
+}
+
+export interface FileVariable {
+  name: string
+  value: string
+}
+
+export interface Capture {
+  name: string
+  expr: string
+  scope: 'runtime' | 'env'
+}
+
+export interface CaptureResult {
+  name: string
+  scope: 'runtime' | 'env'
+  value?: string
+  error?: string
+}
+
+export interface Script {
+  phase: 'pre' | 'post'
+  code?: string
+  file?: string
+}
+
+export interface ScriptResult {
+  phase: 'pre' | 'post'
+  source: string
+  logs?: string[]
+  error?: string
+}
+
+export interface AssertionResult {
+  expr: string
+  passed: boolean
+  actual?: string
+  error?: string
+}
+
 export interface ParsedHttpRequest {
+  name?: string
+  index?: number
+  startLine?: number
+  endLine?: number
   method: string
   url: string
-  headers: Record<string, string>
+  headers: Header[]
   body?: string
   comments: string[]
   docs?: RequestDocs
+  captures?: Capture[]
+  assertions?: string[]
+  scripts?: Script[]
+  noCookieJar?: boolean
+  fileVariables?: FileVariable[]
+  dynamicValues?: Record<string, string>
 }
 
 export interface HttpResponse {
   status: number
   statusText: string
-  headers: Record<string, string>
+  headers: Header[]
   body: string
   time: number
   size: number
   cookies?: CookieInfo[]
+  tls?: TLSInfo
+}
+
+export interface TLSInfo {
+  version: string
+  cipherSuite: string
+}
+
+export interface UnresolvedVariable {
+  name: string
+  reason: 'undefined' | 'cycle' | 'system' | 'filter'
+  field?: string
+  chain?: string[]
+  detail?: string
+}
+
+export interface SecretsCredential {
+  passphrase?: string
+  keyFile?: string
+}
+
+export interface SecretsStatus {
+  exists: boolean
+  unlocked: boolean
+  keys?: string[]
+}
+
+export interface RuntimeValue {
+  value: string
+  updatedAt: number
+  request?: string
+}
+
+export interface EnvSystemRef {
+  key: string
+  variable: string
+}
+
+export interface VariableUsage {
+  name: string
+  path: string
+  line: number
+  request?: string
+  missingIn: string[]
+}
+
+export interface EnvComparison {
+  envs: string[]
+  keys: string[]
+  missing: Record<string, string[]>
+  unresolved: Record<string, EnvSystemRef[]>
+  undefined: VariableUsage[]
+  errors?: Record<string, string>
+}
+
+export interface VariableTrace {
+  field: string
+  start: number
+  end: number
+  expr: string
+  name: string
+  value: string
+  source: 'set' | 'script' | 'data' | 'file' | 'runtime' | 'env' | 'system' | 'request' | 'response' | 'dynamic' | 'literal' | ''
+  resolved: boolean
+  masked?: boolean
+}
+
+export interface ResolvePreview {
+  request: ParsedHttpRequest
+  env?: string
+  variables: VariableTrace[]
+  unresolved?: UnresolvedVariable[]
 }
 
 export interface RunResult {
   request: ParsedHttpRequest
   response: HttpResponse
   error?: string
+  unresolved?: UnresolvedVariable[]
+  captures?: CaptureResult[]
+  assertions?: AssertionResult[]
+  scripts?: ScriptResult[]
+}
+
+export interface CollectionItemResult {
+  path: string
+  index: number
+  iteration?: number
+  folder: string
+  name: string
+  method: string
+  url: string
+  status?: number
+  statusText?: string
+  time: number
+  size?: number
+  passed: boolean
+  error?: string
+  assertions?: AssertionResult[]
+  failures?: string[]
+}
+
+export interface CollectionReport {
+  project: string
+  folder: string
+  env?: string
+  dataFile?: string
+  startedAt: number
+  duration: number
+  total: number
+  passed: number
+  failed: number
+  cancelled?: boolean
+  iterations?: IterationSummary[]
+  items: CollectionItemResult[]
+}
+
+export interface IterationSummary {
+  index: number
+  data: Record<string, string>
+  passed: number
+  failed: number
+}
+
+export interface CollectionProgress {
+  completed: number
+  total: number
+  item: CollectionItemResult
+}
+
+export interface LoadTestConfig {
+  concurrency: number
+  requests?: number
+  duration?: number
+  rps?: number
+}
+
+export interface LatencySummary {
+  min: number
+  mean: number
+  p50: number
+  p90: number
+  p99: number
+  max: number
+}
+
+export interface LatencyBucket {
+  upperBound?: number
+  count: number
+}
+
+export interface LoadTestStats {
+  elapsed: number
+  completed: number
+  failed: number
+  throughput: number
+  errorRate: number
+  statuses: Record<string, number>
+  errors: Record<string, number>
+  latency: LatencySummary
+  histogram: LatencyBucket[]
+}
+
+export interface LoadTestReport {
+  name?: string
+  method: string
+  url: string
+  folder?: string
+  requests?: string[]
+  env?: string
+  startedAt: number
+  config: LoadTestConfig
+  cancelled?: boolean
+  stats: LoadTestStats
+}
+
+export interface JarCookie {
+  name: string
+  value: string
+  domain: string
+  path: string
+  expires?: number
+  secure?: boolean
+  httpOnly?: boolean
+  sameSite?: string
+  hostOnly?: boolean
 }
 
 export interface Project {
@@ -69,6 +302,7 @@ export interface HistoryEntry {
   request: ParsedHttpRequest
   response: HttpResponse
   error?: string
+  assertions?: AssertionResult[]
 }
 
 export interface CookieInfo {
@@ -9,8 +9,8 @@ export function generateCurl(req: ParsedHttpRequest): string {
 
   parts.push(`'${req.url}'`)
 
-  for (const [key, value] of Object.entries(req.headers)) {
-    parts.push(`-H '${key}: ${value}'`)
+  for (const { name, value } of req.headers) {
+    parts.push(`-H '${name}: ${value}'`)
   }
 
   if (req.body) {
@@ -23,7 +23,7 @@ export function generateCurl(req: ParsedHttpRequest): string {
 export function generatePython(req: ParsedHttpRequest): string {
   const lines = ['import requests', '']
 
-  const headers = Object.entries(req.headers)
+  const headers = req.headers.map(({ name, value }) => [name, value])
   if (headers.length > 0) {
     lines.push('headers = {')
     for (const [key, value] of headers) {
@@ -56,7 +56,7 @@ export function generateNodeFetch(req: ParsedHttpRequest): string {
 
   options.push(`  method: "${req.method}"`)
 
-  const headers = Object.entries(req.headers)
+  const headers = req.headers.map(({ name, value }) => [name, value])
   if (headers.length > 0) {
     const headerLines = headers.map(([k, v]) => `    "${k}": "${v}"`).join(',\n')
     options.push(`  headers: {\n${headerLines}\n  }`)
@@ -100,8 +100,8 @@ export function generateGo(req: ParsedHttpRequest): string {
   lines.push('\t\tpanic(err)')
   lines.push('\t}')
 
-  for (const [key, value] of Object.entries(req.headers)) {
-    lines.push(`\treq.Header.Set("${key}", "${value}")`)
+  for (const { name, value } of req.headers) {
+    lines.push(`\treq.Header.Add("${name}", "${value}")`)
   }
 
   lines.push('')
@@ -0,0 +1,13 @@
+import type { Header } from '../store/appStore'
+
+// getHeader returns the first value of a header (case-insensitive), or ''.
+export function getHeader(headers: Header[] | undefined, name: string): string {
+  const lower = name.toLowerCase()
+  return headers?.find((h) => h.name.toLowerCase() === lower)?.value ?? ''
+}
+
+// withoutHeader returns the headers minus every entry named `name`.
+export function withoutHeader(headers: Header[], name: string): Header[] {
+  const lower = name.toLowerCase()
+  return headers.filter((h) => h.name.toLowerCase() !== lower)
+}
@@ -1,16 +1,25 @@
-import type { ParsedHttpRequest } from '../store/appStore'
+import type { FileVariable, Header, ParsedHttpRequest } from '../store/appStore'
 
 export interface RebuildParts {
+  fileVariables?: FileVariable[]
   comments: string[]
   method: string
   url: string
-  headers: Record<string, string>
+  headers: Header[]
   body?: string
 }
 
 export function rebuildHttpContent(parts: RebuildParts): string {
   const lines: string[] = []
 
+  // File variables
+  for (const { name, value } of parts.fileVariables ?? []) {
+    lines.push(`@${name} = ${value}`)
+  }
+  if (parts.fileVariables?.length) {
+    lines.push('')
+  }
+
   // Comments
   for (const comment of parts.comments) {
     lines.push(`# ${comment}`)
@@ -25,8 +34,8 @@ export function rebuildHttpContent(parts: RebuildParts): string {
   lines.push(`${parts.method} ${parts.url}`)
 
   // Headers
-  for (const [key, value] of Object.entries(parts.headers)) {
-    lines.push(`${key}: ${value}`)
+  for (const { name, value } of parts.headers) {
+    lines.push(`${name}: ${value}`)
   }
 
   // Body
@@ -40,6 +49,7 @@ export function rebuildHttpContent(parts: RebuildParts): string {
 
 export function rebuildFromParsed(parsed: ParsedHttpRequest, overrides?: Partial<RebuildParts>): string {
   return rebuildHttpContent({
+    fileVariables: overrides?.fileVariables ?? parsed.fileVariables,
     comments: overrides?.comments ?? parsed.comments,
     method: overrides?.method ?? parsed.method,
     url: overrides?.url ?? parsed.url,
@@ -0,0 +1,124 @@
+// Package cli runs Carmelia headless: `carmelia run` executes the .http
+// files of a project as the app's collection runner does, and `export` and
+// `import` convert collections from and to other tools.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Exit codes. A run whose requests did not all pass exits with
+// ExitFailed; bad arguments and errors before anything ran exit with
+// ExitError.
+const (
+	ExitOK     = 0
+	ExitFailed = 1
+	ExitError  = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"run", "run the requests of a project and report the results", runCommand},
+	{"export", "export a project's requests as a Postman, Insomnia or OpenAPI file", exportCommand},
+	{"import", "import a Postman collection, Insomnia export or curl command", importCommand},
+}
+
+// IsCommand reports whether arg names a subcommand, so that main starts the
+// desktop app for anything else.
+func IsCommand(arg string) bool {
+	if arg == "help" || arg == "-h" || arg == "--help" {
+		return true
+	}
+	for _, c := range commands {
+		if c.name == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes the subcommand named by args[0] and returns the process
+// exit code.
+func Run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage(stdout)
+		return ExitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	usage(stderr)
+	return ExitError
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: carmelia <command> [arguments]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run `carmelia <command> -h` for the options of a command.")
+	fmt.Fprintln(w, "Without a command, the desktop app starts.")
+}
+
+// newFlagSet returns a flag set that reports errors to stderr with the
+// usage line of a command.
+func newFlagSet(name, args string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: carmelia %s %s\n\nOptions:\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags placed before, between or after the positional
+// arguments, which it returns.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// flagExit is the exit code after a flag parsing error: help was asked
+// for, or the error and usage were already printed.
+func flagExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	return ExitError
+}
+
+// expectArgs checks the number of positional arguments.
+func expectArgs(fs *flag.FlagSet, args []string, names ...string) bool {
+	if len(args) == len(names) {
+		return true
+	}
+	fmt.Fprintf(fs.Output(), "expected %s, got %d argument(s)\n", strings.Join(names, " and "), len(args))
+	fs.Usage()
+	return false
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"bytes"
+	"carmelia-desktop/internal/services"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeProject creates a project with the given files, keyed by path
+// under .carmelia/.
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, ".carmelia", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// run runs the CLI and returns its exit code and output.
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunArguments(t *testing.T) {
+	project := writeProject(t, map[string]string{"requests/a.http": "GET http://localhost/\n"})
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		wantErr string
+	}{
+		{name: "unknown command", args: []string{"fly"}, want: ExitError, wantErr: `unknown command "fly"`},
+		{name: "command help", args: []string{"run", "-h"}, want: ExitOK, wantErr: "Usage: carmelia run <project> [options]"},
+		{name: "missing project", args: []string{"run"}, want: ExitError, wantErr: "expected <project>, got 0 argument(s)"},
+		{name: "extra argument", args: []string{"run", project, "more"}, want: ExitError, wantErr: "expected <project>, got 2 argument(s)"},
+		{name: "unknown flag", args: []string{"run", project, "--nope"}, want: ExitError, wantErr: "flag provided but not defined: -nope"},
+		{name: "unknown reporter", args: []string{"run", project, "--reporter", "xml"}, want: ExitError, wantErr: `unknown reporter "xml"`},
+		{name: "flags around the project", args: []string{"run", "--reporter", "json", project, "--env", "nope"}, want: ExitError, wantErr: `environment "nope" not found`},
+		{name: "missing data file", args: []string{"run", project, "--data", "rows.csv"}, want: ExitError, wantErr: "failed to read data file"},
+		{name: "import without a file", args: []string{"import", project}, want: ExitError, wantErr: "expected <project> and <file>, got 1 argument(s)"},
+		{name: "unknown export format", args: []string{"export", project, "--format", "har"}, want: ExitError, wantErr: "har"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := run(tt.args...)
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d", code, tt.want)
+			}
+			if !strings.Contains(stderr, tt.wantErr) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantErr)
+			}
+		})
+	}
+
+	if code, stdout, _ := run("help"); code != ExitOK || !strings.Contains(stdout, "Usage: carmelia <command>") {
+		t.Errorf("help: exit code %d, stdout %q", code, stdout)
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	files := map[string]string{
+		"envs/dev.yaml":            "base: " + server.URL + "\n",
+		"requests/good/ok.http":    "GET {{base}}/ok\n",
+		"requests/empty/notes.txt": "not a request",
+		"requests/bad/x.http":      "# @assert status == 200\nGET {{base}}/fail\n",
+	}
+	project := writeProject(t, files)
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "all passed", args: []string{"run", project, "--env", "dev", "--folder", "good"}, want: ExitOK},
+		{name: "a request failed", args: []string{"run", project, "--env", "dev"}, want: ExitFailed},
+		{name: "no requests", args: []string{"run", project, "--env", "dev", "--folder", "empty"}, want: ExitFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, stdout, stderr := run(tt.args...); code != tt.want {
+				t.Errorf("exit code = %d, want %d\nstdout: %s\nstderr: %s", code, tt.want, stdout, stderr)
+			}
+		})
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer server.Close()
+	project := writeProject(t, map[string]string{"requests/ok.http": "GET " + server.URL + "/ok\n"})
+
+	// The text report is the progress, so it goes to the file
+	path := filepath.Join(t.TempDir(), "report.txt")
+	code, stdout, _ := run("run", project, "--output", path)
+	report, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != ExitOK || stdout != "" || !strings.Contains(string(report), "✓ ok") || !strings.Contains(string(report), "1 passed") {
+		t.Errorf("text: exit code %d, stdout %q, report %q", code, stdout, report)
+	}
+
+	// Other reports go to the file, with progress on stdout
+	path = filepath.Join(t.TempDir(), "report.json")
+	code, stdout, _ = run("run", project, "--reporter", "json", "--output", path)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct{ Passed int }
+	if code != ExitOK || !strings.Contains(stdout, "✓ ok") || json.Unmarshal(data, &parsed) != nil || parsed.Passed != 1 {
+		t.Errorf("json: exit code %d, stdout %q, report %q", code, stdout, data)
+	}
+
+	if code, _, stderr := run("run", project, "--output", filepath.Join(project, "missing", "report.txt")); code != ExitError || !strings.Contains(stderr, "failed to write") {
+		t.Errorf("unwritable output: exit code %d, stderr %q", code, stderr)
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"requests/health.http":       "GET {{base}}/health\n",
+		"requests/users/list.http":   "GET {{base}}/users\nAccept: application/json\n",
+		"requests/users/create.http": "POST {{base}}/users\nContent-Type: application/json\n\n{\"name\": \"Ada\"}\n",
+		"order.json":                 `{"": ["users", "health.http"], "users": ["list.http", "create.http"]}`,
+	}
+	source := writeProject(t, files)
+	collection := filepath.Join(t.TempDir(), "collection.json")
+	if code, _, stderr := run("export", source, "--output", collection); code != ExitOK {
+		t.Fatalf("export: exit code %d, stderr %q", code, stderr)
+	}
+
+	target := writeProject(t, map[string]string{"requests/existing.http": "GET /\n"})
+	code, stdout, stderr := run("import", target, collection, "--folder", "imported")
+	if code != ExitOK {
+		t.Fatalf("import: exit code %d, stderr %q", code, stderr)
+	}
+	wantCreated := "created imported/users/list.http\ncreated imported/users/create.http\ncreated imported/health.http\n"
+	if stdout != wantCreated {
+		t.Errorf("import output = %q, want %q", stdout, wantCreated)
+	}
+	for name, content := range files {
+		if !strings.HasSuffix(name, ".http") {
+			continue
+		}
+		rel := strings.TrimPrefix(name, "requests/")
+		got, err := services.ReadRequest(target, filepath.Join("imported", rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != content {
+			t.Errorf("%s = %q, want %q", rel, got, content)
+		}
+	}
+
+	// New items follow the existing ones, in the collection's order
+	order, err := services.LoadOrder(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := services.OrderMap{
+		"":                                 {"existing.http", "imported"},
+		"imported":                         {"users", "health.http"},
+		filepath.Join("imported", "users"): {"list.http", "create.http"},
+	}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("order = %v, want %v", order, want)
+	}
+}
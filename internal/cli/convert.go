@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"carmelia-desktop/internal/services"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// exportCommand writes every request of a project as a Postman, Insomnia
+// or OpenAPI file.
+func exportCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("export", "<project> [options]", stderr)
+	format := fs.String("format", "postman", "export format: postman, insomnia or openapi")
+	output := fs.String("output", "", "write to a file instead of stdout")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return flagExit(err)
+	}
+	if !expectArgs(fs, positional, "<project>") {
+		return ExitError
+	}
+	projectPath := positional[0]
+
+	requests, err := services.CollectAllRequests(projectPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "error: failed to collect requests: %v\n", err)
+		return ExitError
+	}
+	if len(requests) == 0 {
+		fmt.Fprintln(stderr, "error: no requests found in project")
+		return ExitError
+	}
+
+	content, err := services.GenerateExport(filepath.Base(projectPath), requests, *format)
+	if err == nil {
+		err = writeOutput(stdout, *output, content)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return ExitError
+	}
+	if *output != "" {
+		fmt.Fprintf(stdout, "exported %d requests to %s\n", len(requests), *output)
+	}
+	return ExitOK
+}
+
+// importCommand turns a Postman collection, an Insomnia export or a curl
+// command into .http files of a project.
+func importCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("import", "<project> <file> [options]", stderr)
+	format := fs.String("format", "", "postman, insomnia or curl (default: detected from the file)")
+	folder := fs.String("folder", "", "folder of .carmelia/requests/ to import into")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return flagExit(err)
+	}
+	if !expectArgs(fs, positional, "<project>", "<file>") {
+		return ExitError
+	}
+	projectPath, file := positional[0], positional[1]
+
+	var data []byte
+	if file == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return ExitError
+	}
+
+	created, err := services.ImportCollection(projectPath, *folder, data, *format)
+	for _, path := range created {
+		fmt.Fprintf(stdout, "created %s\n", filepath.ToSlash(path))
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return ExitError
+	}
+	return ExitOK
+}
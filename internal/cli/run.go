@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"carmelia-desktop/internal/models"
+	"carmelia-desktop/internal/services"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+// runCommand runs every request under a folder of a project, as the
+// collection runner does, and reports the results as text, json, junit or
+// html.
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	fs := newFlagSet("run", "<project> [options]", stderr)
+	env := fs.String("env", "", "environment to run against")
+	folder := fs.String("folder", "", "folder of .carmelia/requests/ to run (default: all requests)")
+	data := fs.String("data", "", "CSV or JSON data file to run the requests once per row")
+	reporter := fs.String("reporter", "text", "report format: text, json, junit or html")
+	output := fs.String("output", "", "write the report to a file instead of stdout")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return flagExit(err)
+	}
+	if !expectArgs(fs, positional, "<project>") {
+		return ExitError
+	}
+	projectPath := positional[0]
+
+	switch *reporter {
+	case "text", "json", "junit", "html":
+	default:
+		fmt.Fprintf(stderr, "unknown reporter %q\n", *reporter)
+		return ExitError
+	}
+	if *env != "" {
+		if _, err := services.LoadEnv(projectPath, *env); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	var rows []map[string]string
+	if *data != "" {
+		if rows, err = services.LoadDataFile(projectPath, *data); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	// The text report is the progress itself, written to --output or
+	// stdout. With other reporters progress goes to stdout, or to stderr
+	// when stdout carries the report.
+	progressOut := stdout
+	if *reporter == "text" && *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: failed to write %s: %v\n", *output, err)
+			return ExitError
+		}
+		defer f.Close()
+		progressOut = f
+	} else if *reporter != "text" && *output == "" {
+		progressOut = stderr
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := services.RunCollection(ctx, projectPath, *folder, *env, rows, func(p models.CollectionProgress) {
+		printItem(progressOut, p.Item)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "error: %v\n", err)
+		return ExitError
+	}
+	report.DataFile = *data
+	printSummary(progressOut, report)
+
+	if *reporter != "text" {
+		content, err := services.FormatCollectionReport(report, *reporter)
+		if err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return ExitError
+		}
+		if err := writeOutput(stdout, *output, content); err != nil {
+			fmt.Fprintf(stderr, "error: %v\n", err)
+			return ExitError
+		}
+	}
+
+	if report.Failed > 0 || report.Cancelled || report.Total == 0 {
+		return ExitFailed
+	}
+	return ExitOK
+}
+
+func printItem(w io.Writer, item models.CollectionItemResult) {
+	mark := "✓"
+	if !item.Passed {
+		mark = "✗"
+	}
+	name := item.Name
+	if item.Folder != "" {
+		name = item.Folder + " / " + name
+	}
+	if item.Iteration > 0 {
+		name = fmt.Sprintf("%s [%d]", name, item.Iteration)
+	}
+
+	status := item.StatusText
+	if item.Error != "" {
+		status = "error"
+	}
+	fmt.Fprintf(w, "%s %s  %s %s  %s  %d ms\n", mark, name, item.Method, item.URL, status, item.Time)
+	if item.Error != "" {
+		fmt.Fprintf(w, "    %s\n", item.Error)
+	}
+	for _, f := range item.Failures {
+		fmt.Fprintf(w, "    %s\n", f)
+	}
+}
+
+func printSummary(w io.Writer, report models.CollectionReport) {
+	parts := []string{
+		fmt.Sprintf("%d requests", len(report.Items)),
+		fmt.Sprintf("%d passed", report.Passed),
+		fmt.Sprintf("%d failed", report.Failed),
+	}
+	if len(report.Iterations) > 0 {
+		parts = append(parts, fmt.Sprintf("%d iterations", len(report.Iterations)))
+	}
+	if report.Cancelled {
+		parts = append(parts, fmt.Sprintf("cancelled after %d of %d", len(report.Items), report.Total))
+	}
+	fmt.Fprintf(w, "\n%s (%d ms)\n", strings.Join(parts, ", "), report.Duration)
+}
+
+// writeOutput writes content to path, or to stdout when path is empty.
+func writeOutput(stdout io.Writer, path, content string) error {
+	if path == "" {
+		_, err := io.WriteString(stdout, content)
+		return err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package models
+
+// CollectionReport summarises a run of every request under a folder, or of
+// a single request run once per data file row. StartedAt is in Unix
+// milliseconds, Duration in milliseconds. DataFile and Iterations are set
+// when the run iterated over a data file.
+type CollectionReport struct {
+	Project    string                 `json:"project"`
+	Folder     string                 `json:"folder"`
+	Env        string                 `json:"env,omitempty"`
+	DataFile   string                 `json:"dataFile,omitempty"`
+	StartedAt  int64                  `json:"startedAt"`
+	Duration   int64                  `json:"duration"`
+	Total      int                    `json:"total"`
+	Passed     int                    `json:"passed"`
+	Failed     int                    `json:"failed"`
+	Cancelled  bool                   `json:"cancelled,omitempty"`
+	Iterations []IterationSummary     `json:"iterations,omitempty"`
+	Items      []CollectionItemResult `json:"items"`
+}
+
+// IterationSummary aggregates the requests run with one data file row.
+// Index starts at 1.
+type IterationSummary struct {
+	Index  int               `json:"index"`
+	Data   map[string]string `json:"data"`
+	Passed int               `json:"passed"`
+	Failed int               `json:"failed"`
+}
+
+// CollectionItemResult is one request of a collection run. Path and Index
+// locate it as in SelectRequest; Folder is relative to .carmelia/requests/.
+// Iteration is the data file row it ran with, starting at 1.
+type CollectionItemResult struct {
+	Path       string            `json:"path"`
+	Index      int               `json:"index"`
+	Iteration  int               `json:"iteration,omitempty"`
+	Folder     string            `json:"folder"`
+	Name       string            `json:"name"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Status     int               `json:"status,omitempty"`
+	StatusText string            `json:"statusText,omitempty"`
+	Time       int64             `json:"time"`
+	Size       int               `json:"size,omitempty"`
+	Passed     bool              `json:"passed"`
+	Error      string            `json:"error,omitempty"`
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	Failures   []string          `json:"failures,omitempty"`
+}
+
+// CollectionProgress is emitted after each request of a collection run.
+type CollectionProgress struct {
+	Completed int                  `json:"completed"`
+	Total     int                  `json:"total"`
+	Item      CollectionItemResult `json:"item"`
+}
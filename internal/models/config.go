@@ -1,12 +1,14 @@
 package models
 
 type HttxConfig struct {
-	Version    int              `json:"version" yaml:"version"`
+	Version    int               `json:"version" yaml:"version"`
 	Frameworks []FrameworkSource `json:"frameworks" yaml:"frameworks"`
-	Output     string           `json:"output" yaml:"output"`
-	Generator  GeneratorConfig  `json:"generator" yaml:"generator"`
-	Runner     RunnerConfig     `json:"runner" yaml:"runner"`
-	Defaults   DefaultsConfig   `json:"defaults" yaml:"defaults"`
+	Output     string            `json:"output" yaml:"output"`
+	Generator  GeneratorConfig   `json:"generator" yaml:"generator"`
+	Runner     RunnerConfig      `json:"runner" yaml:"runner"`
+	Defaults   DefaultsConfig    `json:"defaults" yaml:"defaults"`
+	// TLS holds the TLS settings of each environment, by env name
+	TLS map[string]TLSConfig `json:"tls,omitempty" yaml:"tls,omitempty"`
 }
 
 type FrameworkSource struct {
@@ -28,6 +30,31 @@ type RunnerConfig struct {
 	SaveResponses   bool   `json:"saveResponses" yaml:"saveResponses"`
 	ResponsesDir    string `json:"responsesDir" yaml:"responsesDir"`
 	MaxHistory      int    `json:"maxHistory" yaml:"maxHistory"`
+	// OnUnresolved decides what happens when a request still contains
+	// unresolved variables: "warn" sends it and reports them, "error"
+	// refuses to send, "ignore" sends it silently.
+	OnUnresolved string `json:"onUnresolved" yaml:"onUnresolved"`
+	// ScriptTimeout bounds each @pre/@post script, in milliseconds.
+	// ScriptFileAccess lets scripts read project files with files.read.
+	ScriptTimeout    int  `json:"scriptTimeout" yaml:"scriptTimeout"`
+	ScriptFileAccess bool `json:"scriptFileAccess" yaml:"scriptFileAccess"`
+}
+
+// TLSConfig sets up TLS for the requests of an environment. CAFiles are
+// PEM bundles trusted on top of the system roots. The client certificate
+// is either ClientCert and ClientKey (PEM; the key may sit in the cert
+// file) or a PKCS12 file opened with PKCS12Password. MinVersion is "1.0"
+// to "1.3". Paths are relative to the project root, and paths, ServerName
+// and PKCS12Password may use ${VAR}.
+type TLSConfig struct {
+	CAFiles            []string `json:"caFiles,omitempty" yaml:"caFiles,omitempty"`
+	ClientCert         string   `json:"clientCert,omitempty" yaml:"clientCert,omitempty"`
+	ClientKey          string   `json:"clientKey,omitempty" yaml:"clientKey,omitempty"`
+	PKCS12             string   `json:"pkcs12,omitempty" yaml:"pkcs12,omitempty"`
+	PKCS12Password     string   `json:"pkcs12Password,omitempty" yaml:"pkcs12Password,omitempty"`
+	ServerName         string   `json:"serverName,omitempty" yaml:"serverName,omitempty"`
+	MinVersion         string   `json:"minVersion,omitempty" yaml:"minVersion,omitempty"`
+	InsecureSkipVerify bool     `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
 }
 
 type DefaultsConfig struct {
@@ -49,6 +76,8 @@ var DefaultConfig = HttxConfig{
 		SaveResponses:   true,
 		ResponsesDir:    "./.carmelia/responses",
 		MaxHistory:      10,
+		OnUnresolved:    "warn",
+		ScriptTimeout:   5000,
 	},
 	Defaults: DefaultsConfig{
 		Headers: map[string]string{
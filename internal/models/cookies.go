@@ -0,0 +1,17 @@
+package models
+
+// JarCookie is a cookie kept in the cookie jar of a project env. Domain
+// has no leading dot; a HostOnly cookie is sent to Domain only, any other
+// to its subdomains too. Expires is in Unix milliseconds, 0 for a cookie
+// kept until the jar is cleared.
+type JarCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Expires  int64  `json:"expires,omitempty"`
+	Secure   bool   `json:"secure,omitempty"`
+	HttpOnly bool   `json:"httpOnly,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+	HostOnly bool   `json:"hostOnly,omitempty"`
+}
@@ -6,3 +6,33 @@ type EnvFile struct {
 	Name      string       `json:"name"`
 	Variables EnvVariables `json:"variables"`
 }
+
+// EnvComparison compares the environments of a project. Keys include the
+// dotted paths of nested objects, e.g. "db.host".
+type EnvComparison struct {
+	Envs []string `json:"envs"`
+	Keys []string `json:"keys"`
+	// Missing lists, per env, the keys that another env defines
+	Missing map[string][]string `json:"missing"`
+	// Unresolved lists, per env, the keys still holding an unset ${VAR}
+	Unresolved map[string][]EnvSystemRef `json:"unresolved"`
+	// Undefined lists the variables used by requests that at least one env
+	// does not define
+	Undefined []VariableUsage `json:"undefined"`
+	// Errors holds the envs that could not be loaded
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+type EnvSystemRef struct {
+	Key      string `json:"key"`
+	Variable string `json:"variable"`
+}
+
+// VariableUsage is a {{variable}} found in a .http file. Line is 1-based.
+type VariableUsage struct {
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	Line      int      `json:"line"`
+	Request   string   `json:"request,omitempty"`
+	MissingIn []string `json:"missingIn"`
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Header is a single header line, kept with the name case it was written in.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Headers is an ordered, multi-value header list. Repeated names are kept as
+// separate entries; lookups are case-insensitive.
+type Headers []Header
+
+// Get returns the first value for name, or "" if it is not present.
+func (h Headers) Get(name string) string {
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Values returns every value for name, in order.
+func (h Headers) Values(name string) []string {
+	var values []string
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+// Has reports whether at least one header named name is present.
+func (h Headers) Has(name string) bool {
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add appends a header, keeping any existing values for the same name.
+func (h *Headers) Add(name, value string) {
+	*h = append(*h, Header{Name: name, Value: value})
+}
+
+// Set replaces all values for name with a single one. The header keeps the
+// position of its first occurrence, or is appended if it was not present.
+func (h *Headers) Set(name, value string) {
+	out := make(Headers, 0, len(*h)+1)
+	replaced := false
+	for _, header := range *h {
+		if strings.EqualFold(header.Name, name) {
+			if !replaced {
+				out = append(out, Header{Name: header.Name, Value: value})
+				replaced = true
+			}
+			continue
+		}
+		out = append(out, header)
+	}
+	if !replaced {
+		out = append(out, Header{Name: name, Value: value})
+	}
+	*h = out
+}
+
+// Del removes every header named name.
+func (h *Headers) Del(name string) {
+	out := make(Headers, 0, len(*h))
+	for _, header := range *h {
+		if !strings.EqualFold(header.Name, name) {
+			out = append(out, header)
+		}
+	}
+	*h = out
+}
+
+// Clone returns a copy that can be modified independently.
+func (h Headers) Clone() Headers {
+	out := make(Headers, len(h))
+	copy(out, h)
+	return out
+}
+
+// UnmarshalJSON accepts the list form as well as the legacy
+// {"Name": "value"} object written by older history entries.
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	var list []Header
+	if err := json.Unmarshal(data, &list); err == nil {
+		*h = list
+		return nil
+	}
+
+	var legacy map[string]string
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(legacy))
+	for name := range legacy {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	out := make(Headers, 0, len(names))
+	for _, name := range names {
+		out = append(out, Header{Name: name, Value: legacy[name]})
+	}
+	*h = out
+	return nil
+}
@@ -0,0 +1,63 @@
+package models
+
+// LoadTestConfig sets how a load test runs: Concurrency workers send the
+// request until Requests have been sent or Duration (in seconds) has
+// elapsed, whichever comes first. RPS, when set, caps the total rate. In a
+// folder test, every request of the folder counts towards Requests and RPS.
+type LoadTestConfig struct {
+	Concurrency int     `json:"concurrency"`
+	Requests    int     `json:"requests,omitempty"`
+	Duration    int     `json:"duration,omitempty"`
+	RPS         float64 `json:"rps,omitempty"`
+}
+
+// LatencySummary gives response latencies in milliseconds.
+type LatencySummary struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// LatencyBucket counts the responses slower than the previous bucket and
+// at most UpperBound milliseconds. The last bucket has no UpperBound and
+// counts everything slower.
+type LatencyBucket struct {
+	UpperBound float64 `json:"upperBound,omitempty"`
+	Count      int     `json:"count"`
+}
+
+// LoadTestStats is a snapshot of a load test. Elapsed is in milliseconds
+// and Throughput in completed requests per second. Statuses counts
+// responses by status code and Errors counts requests that got no response
+// by error type; ErrorRate is the share of both failures and statuses of
+// 400 and above.
+type LoadTestStats struct {
+	Elapsed    int64           `json:"elapsed"`
+	Completed  int             `json:"completed"`
+	Failed     int             `json:"failed"`
+	Throughput float64         `json:"throughput"`
+	ErrorRate  float64         `json:"errorRate"`
+	Statuses   map[string]int  `json:"statuses"`
+	Errors     map[string]int  `json:"errors"`
+	Latency    LatencySummary  `json:"latency"`
+	Histogram  []LatencyBucket `json:"histogram"`
+}
+
+// LoadTestReport is the outcome of a load test. StartedAt is in Unix
+// milliseconds. A folder test has no Method and URL; Folder and Requests
+// give the folder and the names of the requests it sent in turn.
+type LoadTestReport struct {
+	Name      string         `json:"name,omitempty"`
+	Method    string         `json:"method"`
+	URL       string         `json:"url"`
+	Folder    string         `json:"folder,omitempty"`
+	Requests  []string       `json:"requests,omitempty"`
+	Env       string         `json:"env,omitempty"`
+	StartedAt int64          `json:"startedAt"`
+	Config    LoadTestConfig `json:"config"`
+	Cancelled bool           `json:"cancelled,omitempty"`
+	Stats     LoadTestStats  `json:"stats"`
+}
@@ -12,13 +12,85 @@ type RequestDocs struct {
 	Params      []ParamDoc `json:"params,omitempty"`
 }
 
+// FileVariable is an `@name = value` declaration in a .http file. It is
+// visible to every request of the file.
+type FileVariable struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Capture is a `# @capture [env|runtime] name = expr` directive: after a
+// successful response, the value selected by Expr (a JSONPath such as
+// `$.token`, `header <Name>` or `regex "<pattern>"`) is stored under Name
+// in the active env or, by default, in its runtime store.
+type Capture struct {
+	Name  string `json:"name"`
+	Expr  string `json:"expr"`
+	Scope string `json:"scope"`
+}
+
+// CaptureResult is the outcome of one Capture. Error is set when nothing
+// was stored.
+type CaptureResult struct {
+	Name  string `json:"name"`
+	Scope string `json:"scope"`
+	Value string `json:"value,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// AssertionResult is the outcome of one `# @assert` directive. Actual is
+// the value the assertion was checked against; Error is set when the
+// assertion could not be evaluated.
+type AssertionResult struct {
+	Expr   string `json:"expr"`
+	Passed bool   `json:"passed"`
+	Actual string `json:"actual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Script is a `# @pre` or `# @post` script, run before the request is
+// resolved or after its response arrives: inline Code, or the File of a
+// .js file relative to the request file.
+type Script struct {
+	Phase string `json:"phase"`
+	Code  string `json:"code,omitempty"`
+	File  string `json:"file,omitempty"`
+}
+
+// ScriptResult is the outcome of one Script. Source is its file, or
+// "inline".
+type ScriptResult struct {
+	Phase  string   `json:"phase"`
+	Source string   `json:"source"`
+	Logs   []string `json:"logs,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
 type ParsedHttpRequest struct {
-	Method   string            `json:"method"`
-	URL      string            `json:"url"`
-	Headers  map[string]string `json:"headers"`
-	Body     string            `json:"body,omitempty"`
-	Comments []string          `json:"comments"`
-	Docs     RequestDocs       `json:"docs"`
+	Name      string      `json:"name,omitempty"`
+	Index     int         `json:"index"`
+	StartLine int         `json:"startLine,omitempty"`
+	EndLine   int         `json:"endLine,omitempty"`
+	Method    string      `json:"method"`
+	URL       string      `json:"url"`
+	Headers   Headers     `json:"headers"`
+	Body      string      `json:"body,omitempty"`
+	Comments  []string    `json:"comments"`
+	Docs      RequestDocs `json:"docs"`
+
+	// Captures and Assertions (the expressions of `# @assert` directives)
+	// run against the response
+	Captures   []Capture `json:"captures,omitempty"`
+	Assertions []string  `json:"assertions,omitempty"`
+	Scripts    []Script  `json:"scripts,omitempty"`
+	// NoCookieJar is set by `# @no-cookie-jar`: the request neither sends
+	// nor stores the cookies of the env's cookie jar.
+	NoCookieJar bool `json:"noCookieJar,omitempty"`
+
+	FileVariables []FileVariable `json:"fileVariables,omitempty"`
+	// DynamicValues records the {{$...}} values used when the request was
+	// resolved, keyed by expression.
+	DynamicValues map[string]string `json:"dynamicValues,omitempty"`
 }
 
 type CookieInfo struct {
@@ -33,18 +105,66 @@ type CookieInfo struct {
 	HttpOnly bool   `json:"httpOnly"`
 }
 
+// TLSInfo describes the TLS connection a response came over.
+type TLSInfo struct {
+	Version     string `json:"version"`
+	CipherSuite string `json:"cipherSuite"`
+}
+
 type HttpResponse struct {
-	Status     int               `json:"status"`
-	StatusText string            `json:"statusText"`
-	Headers    map[string]string `json:"headers"`
-	Body       string            `json:"body"`
-	Time       int64             `json:"time"`
-	Size       int               `json:"size"`
-	Cookies    []CookieInfo      `json:"cookies,omitempty"`
+	Status     int          `json:"status"`
+	StatusText string       `json:"statusText"`
+	Headers    Headers      `json:"headers"`
+	Body       string       `json:"body"`
+	Time       int64        `json:"time"`
+	Size       int          `json:"size"`
+	Cookies    []CookieInfo `json:"cookies,omitempty"`
+	TLS        *TLSInfo     `json:"tls,omitempty"`
+}
+
+// UnresolvedVariable is a placeholder that could not be resolved. Reason is
+// "undefined", "cycle" (Chain lists the loop), "system" for an unset
+// ${VAR} or "filter" when a pipe filter is unknown or fails (Name is the
+// filter, Detail the error). Field is "url", "body" or "header <Name>".
+type UnresolvedVariable struct {
+	Name   string   `json:"name"`
+	Reason string   `json:"reason"`
+	Field  string   `json:"field,omitempty"`
+	Chain  []string `json:"chain,omitempty"`
+	Detail string   `json:"detail,omitempty"`
+}
+
+// VariableTrace describes how one placeholder of a request was resolved.
+// Start and End are byte offsets of Expr within Field's text. Source is
+// "set", "script", "data", "file", "runtime", "env", "system", "request",
+// "response", "dynamic" or "literal", and empty when the placeholder stayed
+// unresolved.
+type VariableTrace struct {
+	Field    string `json:"field"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Expr     string `json:"expr"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Source   string `json:"source"`
+	Resolved bool   `json:"resolved"`
+	Masked   bool   `json:"masked,omitempty"`
+}
+
+// ResolvePreview is a request resolved without being sent.
+type ResolvePreview struct {
+	Request    ParsedHttpRequest    `json:"request"`
+	Env        string               `json:"env,omitempty"`
+	Variables  []VariableTrace      `json:"variables"`
+	Unresolved []UnresolvedVariable `json:"unresolved,omitempty"`
 }
 
 type RunResult struct {
-	Request  ParsedHttpRequest `json:"request"`
-	Response HttpResponse      `json:"response"`
-	Error    string            `json:"error,omitempty"`
+	Request    ParsedHttpRequest    `json:"request"`
+	Response   HttpResponse         `json:"response"`
+	Error      string               `json:"error,omitempty"`
+	Unresolved []UnresolvedVariable `json:"unresolved,omitempty"`
+	Captures   []CaptureResult      `json:"captures,omitempty"`
+	Assertions []AssertionResult    `json:"assertions,omitempty"`
+	Scripts    []ScriptResult       `json:"scripts,omitempty"`
 }
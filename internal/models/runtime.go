@@ -0,0 +1,12 @@
+package models
+
+// RuntimeValue is a variable set while running requests, such as a token
+// captured from a login response. UpdatedAt is in Unix milliseconds;
+// Request names the request that set it and is empty for manual edits.
+type RuntimeValue struct {
+	Value     string `json:"value"`
+	UpdatedAt int64  `json:"updatedAt"`
+	Request   string `json:"request,omitempty"`
+}
+
+type RuntimeVariables map[string]RuntimeValue
@@ -0,0 +1,16 @@
+package models
+
+// SecretsCredential unlocks an env's secrets file: either a passphrase or
+// the path of a key file.
+type SecretsCredential struct {
+	Passphrase string `json:"passphrase,omitempty"`
+	KeyFile    string `json:"keyFile,omitempty"`
+}
+
+// SecretsStatus describes the secrets file of an env. Keys is only filled
+// while the file is unlocked.
+type SecretsStatus struct {
+	Exists   bool     `json:"exists"`
+	Unlocked bool     `json:"unlocked"`
+	Keys     []string `json:"keys,omitempty"`
+}
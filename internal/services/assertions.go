@@ -0,0 +1,201 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// assertOperators are the comparison operators of `# @assert`. An operator
+// is a word of its own, matched exactly, so it must be separated from the
+// subject and the value by spaces.
+var assertOperators = []string{"==", "!=", "<=", ">=", "<", ">", "!contains", "contains", "matches", "!exists", "exists"}
+
+// maxAssertActual bounds the actual value kept in a result, so asserting
+// on a large body does not copy it into history.
+const maxAssertActual = 200
+
+// assertion is a parsed `# @assert <subject> <operator> [expected]`.
+type assertion struct {
+	subject  string
+	header   string
+	operator string
+	expected string
+}
+
+// RunAssertions evaluates the assertions of a request against its response.
+func RunAssertions(exprs []string, resp models.HttpResponse) []models.AssertionResult {
+	results := make([]models.AssertionResult, 0, len(exprs))
+	for _, expr := range exprs {
+		result := models.AssertionResult{Expr: expr}
+		a, err := parseAssertion(expr)
+		if err == nil {
+			result.Actual, result.Passed, err = a.eval(resp)
+		}
+		if len(result.Actual) > maxAssertActual {
+			cut := maxAssertActual
+			for cut > 0 && !utf8.RuneStart(result.Actual[cut]) {
+				cut--
+			}
+			result.Actual = result.Actual[:cut] + "…"
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// parseAssertion reads an assertion. The subject is `status`, `time` (ms),
+// `size` (bytes), `body`, `header <Name>` or a JSONPath into the body; the
+// expected value may be quoted.
+func parseAssertion(expr string) (assertion, error) {
+	fields := strings.Fields(expr)
+	if len(fields) < 2 {
+		return assertion{}, fmt.Errorf("expected <subject> <operator> [value]")
+	}
+
+	a := assertion{subject: fields[0]}
+	rest := fields[1:]
+	switch {
+	case a.subject == "header":
+		a.header = rest[0]
+		rest = rest[1:]
+	case a.subject == "status", a.subject == "time", a.subject == "size", a.subject == "body", strings.HasPrefix(a.subject, "$"):
+	default:
+		return assertion{}, fmt.Errorf("unknown subject %q", a.subject)
+	}
+	if len(rest) == 0 {
+		return assertion{}, fmt.Errorf("missing operator")
+	}
+
+	for _, op := range assertOperators {
+		if rest[0] == op {
+			a.operator = op
+			break
+		}
+	}
+	if a.operator == "" {
+		return assertion{}, fmt.Errorf("unknown operator %q", rest[0])
+	}
+
+	// Keep the expected value as written, spaces included
+	_, after, _ := strings.Cut(expr, " "+a.operator)
+	a.expected = unquoteAssertValue(strings.TrimSpace(after))
+	if a.operator == "exists" || a.operator == "!exists" {
+		if a.expected != "" {
+			return assertion{}, fmt.Errorf("%s takes no value", a.operator)
+		}
+	} else if len(rest) < 2 {
+		return assertion{}, fmt.Errorf("%s needs a value", a.operator)
+	}
+	return a, nil
+}
+
+func unquoteAssertValue(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// eval returns the actual value of the subject and whether it satisfies
+// the assertion.
+func (a assertion) eval(resp models.HttpResponse) (string, bool, error) {
+	actual, exists, err := a.actual(resp)
+	if err != nil {
+		return "", false, err
+	}
+
+	switch a.operator {
+	case "exists":
+		return actual, exists, nil
+	case "!exists":
+		return actual, !exists, nil
+	}
+	if !exists {
+		return "", false, nil
+	}
+
+	switch a.operator {
+	case "contains":
+		return actual, strings.Contains(actual, a.expected), nil
+	case "!contains":
+		return actual, !strings.Contains(actual, a.expected), nil
+	case "matches":
+		re, err := regexp.Compile(a.expected)
+		if err != nil {
+			return actual, false, fmt.Errorf("invalid regex: %w", err)
+		}
+		return actual, re.MatchString(actual), nil
+	}
+
+	cmp, err := compareAssertValues(actual, a.expected, a.operator)
+	if err != nil {
+		return actual, false, err
+	}
+	switch a.operator {
+	case "==":
+		return actual, cmp == 0, nil
+	case "!=":
+		return actual, cmp != 0, nil
+	case "<":
+		return actual, cmp < 0, nil
+	case "<=":
+		return actual, cmp <= 0, nil
+	case ">":
+		return actual, cmp > 0, nil
+	}
+	return actual, cmp >= 0, nil
+}
+
+func (a assertion) actual(resp models.HttpResponse) (string, bool, error) {
+	switch a.subject {
+	case "status":
+		return strconv.Itoa(resp.Status), true, nil
+	case "time":
+		return strconv.FormatInt(resp.Time, 10), true, nil
+	case "size":
+		return strconv.Itoa(resp.Size), true, nil
+	case "body":
+		return resp.Body, true, nil
+	case "header":
+		return resp.Headers.Get(a.header), resp.Headers.Has(a.header), nil
+	}
+
+	values, err := QueryJSON(resp.Body, a.subject)
+	if err != nil {
+		return "", false, err
+	}
+	switch len(values) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return formatJSONValue(values[0]), true, nil
+	}
+	return formatJSONValue(values), true, nil
+}
+
+// compareAssertValues compares numerically when both sides are numbers,
+// otherwise as strings. Ordering operators require numbers.
+func compareAssertValues(actual, expected, operator string) (int, error) {
+	x, errX := strconv.ParseFloat(actual, 64)
+	y, errY := strconv.ParseFloat(expected, 64)
+	if errX == nil && errY == nil {
+		switch {
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if operator != "==" && operator != "!=" {
+		return 0, fmt.Errorf("%s compares numbers, got %q and %q", operator, actual, expected)
+	}
+	return strings.Compare(actual, expected), nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestParseAssertion(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    assertion
+		wantErr string
+	}{
+		{expr: "status == 200", want: assertion{subject: "status", operator: "==", expected: "200"}},
+		{expr: "time < 500", want: assertion{subject: "time", operator: "<", expected: "500"}},
+		{expr: "size <= 1024", want: assertion{subject: "size", operator: "<=", expected: "1024"}},
+		{expr: "header Content-Type contains json", want: assertion{subject: "header", header: "Content-Type", operator: "contains", expected: "json"}},
+		{expr: `body !contains "an error"`, want: assertion{subject: "body", operator: "!contains", expected: "an error"}},
+		{expr: "$.user.name == John  Smith", want: assertion{subject: "$.user.name", operator: "==", expected: "John  Smith"}},
+		{expr: "$.id exists", want: assertion{subject: "$.id", operator: "exists"}},
+		{expr: "header X-Debug !exists", want: assertion{subject: "header", header: "X-Debug", operator: "!exists"}},
+		{expr: "status", wantErr: "expected <subject> <operator> [value]"},
+		{expr: "latency < 5", wantErr: `unknown subject "latency"`},
+		{expr: "status <=200", wantErr: `unknown operator "<=200"`},
+		{expr: "status ~ 200", wantErr: `unknown operator "~"`},
+		{expr: "status ==", wantErr: "== needs a value"},
+		{expr: "$.id exists 1", wantErr: "exists takes no value"},
+		{expr: "header X-A", wantErr: "missing operator"},
+	}
+	for _, tt := range tests {
+		got, err := parseAssertion(tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("parseAssertion(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseAssertion(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAssertion(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRunAssertions(t *testing.T) {
+	resp := models.HttpResponse{
+		Status: 201,
+		Time:   120,
+		Size:   64,
+		Headers: models.Headers{
+			{Name: "Content-Type", Value: "application/json; charset=utf-8"},
+		},
+		Body: `{"id": 7, "name": "Ada", "tags": ["a", "b"], "active": true}`,
+	}
+	tests := []struct {
+		expr    string
+		passed  bool
+		actual  string
+		wantErr bool
+	}{
+		{expr: "status == 201", passed: true, actual: "201"},
+		{expr: "status != 201", passed: false, actual: "201"},
+		{expr: "status >= 200", passed: true, actual: "201"},
+		{expr: "status < 300", passed: true, actual: "201"},
+		{expr: "time > 200", passed: false, actual: "120"},
+		{expr: "size == 64.0", passed: true, actual: "64"},
+		{expr: "header content-type contains json", passed: true, actual: "application/json; charset=utf-8"},
+		{expr: "header X-Missing exists", passed: false},
+		{expr: "header X-Missing !exists", passed: true},
+		{expr: "header X-Missing == x", passed: false},
+		{expr: "$.id == 7", passed: true, actual: "7"},
+		{expr: "$.name == 'Ada'", passed: true, actual: "Ada"},
+		{expr: "$.name matches ^A[a-z]+$", passed: true, actual: "Ada"},
+		{expr: "$.active == true", passed: true, actual: "true"},
+		{expr: "$.missing exists", passed: false},
+		{expr: "body contains Ada", passed: true},
+		{expr: "$.name > 3", wantErr: true, actual: "Ada"},
+		{expr: "$.name matches (", wantErr: true, actual: "Ada"},
+		{expr: "status ~ 200", wantErr: true},
+	}
+	for _, tt := range tests {
+		results := RunAssertions([]string{tt.expr}, resp)
+		if len(results) != 1 {
+			t.Fatalf("RunAssertions(%q) returned %d results", tt.expr, len(results))
+		}
+		r := results[0]
+		if (r.Error != "") != tt.wantErr {
+			t.Errorf("%q error = %q, wantErr %v", tt.expr, r.Error, tt.wantErr)
+		}
+		if r.Passed != tt.passed {
+			t.Errorf("%q passed = %v, want %v", tt.expr, r.Passed, tt.passed)
+		}
+		if tt.actual != "" && r.Actual != tt.actual {
+			t.Errorf("%q actual = %q, want %q", tt.expr, r.Actual, tt.actual)
+		}
+	}
+}
+
+func TestRunAssertionsTruncatesActual(t *testing.T) {
+	body := strings.Repeat("é", maxAssertActual)
+	r := RunAssertions([]string{"body contains x"}, models.HttpResponse{Body: body})[0]
+	if !strings.HasSuffix(r.Actual, "…") {
+		t.Fatalf("actual not truncated: %d bytes", len(r.Actual))
+	}
+	trimmed := strings.TrimSuffix(r.Actual, "…")
+	if len(trimmed) > maxAssertActual || !strings.HasPrefix(body, trimmed) {
+		t.Errorf("actual cut badly: %q", trimmed)
+	}
+}
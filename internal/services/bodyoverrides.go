@@ -0,0 +1,148 @@
+package services
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bodyOverride is a Sets entry applied to the request body.
+//
+// The key is a JSONPath: `address.city`, `items[0].qty`, `$.meta.tags[*]`.
+// Plain paths only replace values that already exist, so variables passed
+// through Sets do not leak into the body. Paths starting with `$` also add
+// missing fields. A leading `-` (`-$.debug`) removes the field instead.
+type bodyOverride struct {
+	steps  []jsonPathStep
+	value  string
+	create bool
+	remove bool
+}
+
+func parseBodyOverride(key, value string) (bodyOverride, bool) {
+	o := bodyOverride{value: value}
+	if strings.HasPrefix(key, "-") {
+		o.remove = true
+		key = key[1:]
+	}
+	o.create = strings.HasPrefix(key, "$")
+
+	steps, err := parseJSONPath(key)
+	if err != nil || len(steps) == 0 {
+		return o, false
+	}
+	for _, step := range steps {
+		if step.recursive {
+			return o, false
+		}
+	}
+	o.steps = steps
+	return o, true
+}
+
+// applyBodyOverrides applies Sets to a JSON or form-encoded body, keeping
+// everything it does not touch exactly as written. Overrides are applied in
+// key order, so `address` is set before `address.city`.
+func applyBodyOverrides(body, contentType string, sets map[string]string) string {
+	keys := make([]string, 0, len(sets))
+	for k := range sets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var overrides []bodyOverride
+	for _, k := range keys {
+		if o, ok := parseBodyOverride(k, sets[k]); ok {
+			overrides = append(overrides, o)
+		}
+	}
+	if len(overrides) == 0 {
+		return body
+	}
+
+	if _, err := parseLocatedJSON(body); err == nil {
+		return applyJSONOverrides(body, overrides)
+	}
+	if strings.Contains(strings.ToLower(contentType), "application/x-www-form-urlencoded") {
+		return applyFormOverrides(body, overrides)
+	}
+	return body
+}
+
+func applyJSONOverrides(body string, overrides []bodyOverride) string {
+	for _, o := range overrides {
+		root, err := parseLocatedJSON(body)
+		if err != nil {
+			return body
+		}
+		editor := jsonEditor{data: body}
+		var edits []jsonEdit
+		if o.remove {
+			edits = editor.remove(root, o.steps)
+		} else {
+			edits = editor.set(root, o.steps, jsonOverrideValue(o.value), o.create)
+		}
+		body = applyJSONEdits(body, edits)
+	}
+	return body
+}
+
+// applyFormOverrides applies overrides to an `a=1&b=2` body. A field
+// matches a path written either as-is (`address.city`) or in bracket form
+// (`address[city]`, `items[0][qty]`).
+func applyFormOverrides(body string, overrides []bodyOverride) string {
+	var fields []string
+	if body != "" {
+		fields = strings.Split(body, "&")
+	}
+
+	for _, o := range overrides {
+		names := formFieldNames(o)
+		if len(names) == 0 {
+			continue
+		}
+
+		out := fields[:0:0]
+		found := false
+		for _, field := range fields {
+			rawName, _, _ := strings.Cut(field, "=")
+			name, err := url.QueryUnescape(rawName)
+			if err != nil || (name != names[0] && name != names[1]) {
+				out = append(out, field)
+				continue
+			}
+			found = true
+			if !o.remove {
+				out = append(out, rawName+"="+url.QueryEscape(o.value))
+			}
+		}
+		if !found && o.create && !o.remove {
+			out = append(out, url.QueryEscape(names[0])+"="+url.QueryEscape(o.value))
+		}
+		fields = out
+	}
+	return strings.Join(fields, "&")
+}
+
+// formFieldNames returns the dotted and bracket field names an override
+// path can match. New fields are added under the dotted name.
+func formFieldNames(o bodyOverride) []string {
+	var dotted, bracket strings.Builder
+	for i, step := range o.steps {
+		switch {
+		case step.wildcard:
+			return nil
+		case step.isIndex:
+			dotted.WriteString("[" + strconv.Itoa(step.index) + "]")
+			bracket.WriteString("[" + strconv.Itoa(step.index) + "]")
+		case i == 0:
+			dotted.WriteString(step.key)
+			bracket.WriteString(step.key)
+		default:
+			dotted.WriteString("." + step.key)
+			bracket.WriteString("[" + step.key + "]")
+		}
+	}
+	return []string{dotted.String(), bracket.String()}
+}
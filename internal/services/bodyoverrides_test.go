@@ -0,0 +1,129 @@
+package services
+
+import "testing"
+
+func TestApplyBodyOverridesJSON(t *testing.T) {
+	body := `{
+  "name": "Ada",
+  "address": {"city": "London", "zip": "N1"},
+  "items": [
+    {"qty": 1},
+    {"qty": 2}
+  ],
+  "debug": true
+}`
+	tests := []struct {
+		name string
+		sets map[string]string
+		want string
+	}{
+		{
+			name: "plain path replaces an existing value",
+			sets: map[string]string{"address.city": "Paris"},
+			want: `{
+  "name": "Ada",
+  "address": {"city": "Paris", "zip": "N1"},
+  "items": [
+    {"qty": 1},
+    {"qty": 2}
+  ],
+  "debug": true
+}`,
+		},
+		{
+			name: "plain path never adds a field",
+			sets: map[string]string{"token": "x", "address.country": "UK"},
+			want: body,
+		},
+		{
+			name: "JSON values are inserted as written",
+			sets: map[string]string{"items[1].qty": "5", "debug": "false", "name": `{"first": "Ada"}`},
+			want: `{
+  "name": {"first": "Ada"},
+  "address": {"city": "London", "zip": "N1"},
+  "items": [
+    {"qty": 1},
+    {"qty": 5}
+  ],
+  "debug": false
+}`,
+		},
+		{
+			name: "wildcard",
+			sets: map[string]string{"$.items[*].qty": "0"},
+			want: `{
+  "name": "Ada",
+  "address": {"city": "London", "zip": "N1"},
+  "items": [
+    {"qty": 0},
+    {"qty": 0}
+  ],
+  "debug": true
+}`,
+		},
+		{
+			name: "$ path adds missing fields with the siblings' indentation",
+			sets: map[string]string{"$.meta.source": "cli"},
+			want: `{
+  "name": "Ada",
+  "address": {"city": "London", "zip": "N1"},
+  "items": [
+    {"qty": 1},
+    {"qty": 2}
+  ],
+  "debug": true,
+  "meta": {"source": "cli"}
+}`,
+		},
+		{
+			name: "leading - removes a field",
+			sets: map[string]string{"-$.debug": "", "-items[0]": ""},
+			want: `{
+  "name": "Ada",
+  "address": {"city": "London", "zip": "N1"},
+  "items": [
+    {"qty": 2}
+  ]
+}`,
+		},
+		{
+			name: "recursive descent is ignored",
+			sets: map[string]string{"$..qty": "9"},
+			want: body,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applyBodyOverrides(body, "application/json", tt.sets); got != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyBodyOverridesForm(t *testing.T) {
+	const form = "application/x-www-form-urlencoded"
+	tests := []struct {
+		body string
+		sets map[string]string
+		want string
+	}{
+		{"a=1&b=2", map[string]string{"b": "x y"}, "a=1&b=x+y"},
+		{"a=1&b=2", map[string]string{"c": "3"}, "a=1&b=2"},
+		{"a=1", map[string]string{"$.c": "3"}, "a=1&c=3"},
+		{"a=1&b=2", map[string]string{"-b": ""}, "a=1"},
+		{"address%5Bcity%5D=London", map[string]string{"address.city": "Paris"}, "address%5Bcity%5D=Paris"},
+		{"items[0][qty]=1", map[string]string{"items[0].qty": "2"}, "items[0][qty]=2"},
+		{"", map[string]string{"$.a": "1"}, "a=1"},
+	}
+	for _, tt := range tests {
+		if got := applyBodyOverrides(tt.body, form, tt.sets); got != tt.want {
+			t.Errorf("applyBodyOverrides(%q, %v) = %q, want %q", tt.body, tt.sets, got, tt.want)
+		}
+	}
+
+	// Other bodies are left alone
+	if got := applyBodyOverrides("a=1", "text/plain", map[string]string{"a": "2"}); got != "a=1" {
+		t.Errorf("plain text body = %q", got)
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Capture scopes: where a captured value is stored.
+const (
+	CaptureRuntime = "runtime"
+	CaptureEnv     = "env"
+)
+
+// RunCaptures evaluates the captures of a request against its response and
+// stores the values of each scope in one write: runtime values in the
+// env's runtime store, labelled with request, and env values in the env
+// file. Every capture gets a result; failed ones carry an error and store
+// nothing.
+func RunCaptures(projectPath, envName, request string, captures []models.Capture, resp models.HttpResponse) []models.CaptureResult {
+	results := make([]models.CaptureResult, 0, len(captures))
+	values := map[string]map[string]string{}
+	for _, capture := range captures {
+		result := models.CaptureResult{Name: capture.Name, Scope: capture.Scope}
+		value, err := evalCapture(capture.Expr, resp)
+		switch {
+		case err != nil:
+			result.Error = err.Error()
+		case capture.Scope == CaptureEnv && envName == "":
+			result.Error = "no environment selected"
+		default:
+			result.Value = value
+			if values[capture.Scope] == nil {
+				values[capture.Scope] = map[string]string{}
+			}
+			values[capture.Scope][capture.Name] = value
+		}
+		results = append(results, result)
+	}
+
+	for scope, scoped := range values {
+		var err error
+		if scope == CaptureEnv {
+			err = SetEnvValues(projectPath, envName, scoped)
+		} else {
+			err = SetRuntimeValues(projectPath, envName, scoped, request)
+		}
+		if err == nil {
+			continue
+		}
+		for i := range results {
+			if results[i].Scope == scope && results[i].Error == "" {
+				results[i].Value = ""
+				results[i].Error = err.Error()
+			}
+		}
+	}
+	return results
+}
+
+// evalCapture selects a value from a response. expr is a JSONPath on the
+// body (`$.data.token`), `header <Name>`, or `regex "<pattern>"` matched
+// against the body, yielding the first group when the pattern has one.
+func evalCapture(expr string, resp models.HttpResponse) (string, error) {
+	kind, arg, _ := strings.Cut(expr, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		values, err := QueryJSON(resp.Body, expr)
+		if err != nil {
+			return "", err
+		}
+		switch len(values) {
+		case 0:
+			return "", fmt.Errorf("%s matched nothing", expr)
+		case 1:
+			return formatJSONValue(values[0]), nil
+		}
+		return formatJSONValue(values), nil
+
+	case kind == "header":
+		if arg == "" {
+			return "", fmt.Errorf("missing header name")
+		}
+		if !resp.Headers.Has(arg) {
+			return "", fmt.Errorf("header %s not found", arg)
+		}
+		return resp.Headers.Get(arg), nil
+
+	case kind == "regex":
+		pattern := unquoteCapturePattern(arg)
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return "", fmt.Errorf("invalid regex: %w", err)
+		}
+		m := re.FindStringSubmatch(resp.Body)
+		if m == nil {
+			return "", fmt.Errorf("regex %s matched nothing", pattern)
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	}
+	return "", fmt.Errorf("unknown capture %q", expr)
+}
+
+// unquoteCapturePattern strips the quotes around a regex. Backslashes are
+// kept for the regex itself, except in an escaped quote.
+func unquoteCapturePattern(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		q := string(s[0])
+		return strings.ReplaceAll(s[1:len(s)-1], `\`+q, q)
+	}
+	return s
+}
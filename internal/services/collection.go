@@ -0,0 +1,228 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RunCollection runs every request under folder (relative to
+// .carmelia/requests/, empty for all of them) one after the other, in file
+// tree order, against envName. When data holds the rows of a data file,
+// the whole folder runs once per row with the row's columns as variables.
+// progress, when set, is called after each request. Cancelling ctx stops
+// the run after the current request.
+func RunCollection(ctx context.Context, projectPath, folder, envName string, data []map[string]string, progress func(models.CollectionProgress)) (models.CollectionReport, error) {
+	requests, err := collectFolder(projectPath, folder)
+	if err != nil {
+		return models.CollectionReport{}, err
+	}
+
+	report := models.CollectionReport{
+		Project: filepath.Base(projectPath),
+		Folder:  folder,
+		Env:     envName,
+	}
+
+	files := map[string][]models.ParsedHttpRequest{}
+	err = runIterations(ctx, &report, requests, data, progress, func(req exportedRequest, row map[string]string) (models.RunResult, error) {
+		file, ok := files[req.Path]
+		if !ok {
+			content, err := ReadRequest(projectPath, req.Path)
+			if err != nil {
+				return models.RunResult{}, err
+			}
+			file = ParseHttpFileAll(content)
+			files[req.Path] = file
+		}
+
+		run := RequestRun{
+			ProjectPath: projectPath,
+			EnvName:     envName,
+			File:        file,
+			FileKey:     req.Path,
+			Data:        row,
+		}
+		return RunRequest(run, req.Parsed, RequestHistoryKey(req.Path, req.Parsed, len(file)), map[string]string{}), nil
+	})
+	return report, err
+}
+
+// RunRequestIterations runs one request of run.File once per data file
+// row, with the row's columns as variables, and reports the results like
+// a collection run.
+func RunRequestIterations(ctx context.Context, run RequestRun, parsed models.ParsedHttpRequest, historyKey string, sets map[string]string, data []map[string]string, progress func(models.CollectionProgress)) models.CollectionReport {
+	req := exportedRequest{
+		Name:   parsed.Name,
+		Index:  parsed.Index,
+		Parsed: parsed,
+	}
+	if req.Name == "" {
+		req.Name = parsed.Method + " " + parsed.URL
+	}
+	if strings.HasSuffix(run.FileKey, ".http") {
+		req.Path = run.FileKey
+	}
+
+	report := models.CollectionReport{
+		Project: filepath.Base(run.ProjectPath),
+		Env:     run.EnvName,
+	}
+	runIterations(ctx, &report, []exportedRequest{req}, data, progress, func(req exportedRequest, row map[string]string) (models.RunResult, error) {
+		iteration := run
+		iteration.Data = row
+		return RunRequest(iteration, req.Parsed, historyKey, sets), nil
+	})
+	return report
+}
+
+// runIterations runs requests in order through exec, once per data row or
+// once without data, and records the results in report.
+func runIterations(ctx context.Context, report *models.CollectionReport, requests []exportedRequest, data []map[string]string, progress func(models.CollectionProgress), exec func(exportedRequest, map[string]string) (models.RunResult, error)) error {
+	started := time.Now()
+	report.StartedAt = started.UnixMilli()
+	report.Items = []models.CollectionItemResult{}
+	defer func() {
+		report.Duration = time.Since(started).Milliseconds()
+	}()
+
+	rows := data
+	if len(rows) == 0 {
+		rows = []map[string]string{nil}
+	}
+	report.Total = len(requests) * len(rows)
+
+	for i, row := range rows {
+		var summary *models.IterationSummary
+		if len(data) > 0 {
+			report.Iterations = append(report.Iterations, models.IterationSummary{Index: i + 1, Data: row})
+			summary = &report.Iterations[i]
+		}
+
+		for _, req := range requests {
+			if ctx.Err() != nil {
+				report.Cancelled = true
+				return nil
+			}
+
+			result, err := exec(req, row)
+			if err != nil {
+				return err
+			}
+
+			item := collectionItem(req, result)
+			if summary != nil {
+				item.Iteration = summary.Index
+			}
+			report.Items = append(report.Items, item)
+			if item.Passed {
+				report.Passed++
+			} else {
+				report.Failed++
+			}
+			if summary != nil {
+				if item.Passed {
+					summary.Passed++
+				} else {
+					summary.Failed++
+				}
+			}
+			if progress != nil {
+				progress(models.CollectionProgress{Completed: len(report.Items), Total: report.Total, Item: item})
+			}
+		}
+	}
+	return nil
+}
+
+// collectFolder lists the requests under a folder in file tree order.
+func collectFolder(projectPath, folder string) ([]exportedRequest, error) {
+	tree, err := BuildFileTree(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	folder = strings.Trim(filepath.ToSlash(folder), "/")
+	nodes := tree
+	if folder != "" {
+		node, ok := findTreeNode(tree, filepath.FromSlash(folder))
+		if !ok || !node.IsDir {
+			return nil, fmt.Errorf("folder %q not found", folder)
+		}
+		nodes = node.Children
+	}
+
+	var requests []exportedRequest
+	collectFromTree(projectPath, nodes, folder, &requests)
+	return requests, nil
+}
+
+func findTreeNode(nodes []models.FileTreeNode, path string) (models.FileTreeNode, bool) {
+	for _, node := range nodes {
+		if node.Path == path {
+			return node, true
+		}
+		if node.IsDir && strings.HasPrefix(path, node.Path+string(filepath.Separator)) {
+			return findTreeNode(node.Children, path)
+		}
+	}
+	return models.FileTreeNode{}, false
+}
+
+const assertionFailurePrefix = "assertion failed: "
+
+// collectionItem summarises one result. A request fails when it could not
+// be sent, when an assertion, capture or script fails, or, for requests
+// without assertions, when the status is 400 or above.
+func collectionItem(req exportedRequest, result models.RunResult) models.CollectionItemResult {
+	item := models.CollectionItemResult{
+		Path:       req.Path,
+		Index:      req.Index,
+		Folder:     req.Folder,
+		Name:       req.Name,
+		Method:     result.Request.Method,
+		URL:        result.Request.URL,
+		Status:     result.Response.Status,
+		StatusText: result.Response.StatusText,
+		Time:       result.Response.Time,
+		Size:       result.Response.Size,
+		Error:      result.Error,
+		Assertions: result.Assertions,
+	}
+	if item.Method == "" {
+		item.Method = req.Parsed.Method
+		item.URL = req.Parsed.URL
+	}
+
+	for _, a := range result.Assertions {
+		if a.Passed {
+			continue
+		}
+		msg := assertionFailurePrefix + a.Expr
+		if a.Error != "" {
+			msg += " (" + a.Error + ")"
+		} else if a.Actual != "" {
+			msg += " (got " + a.Actual + ")"
+		}
+		item.Failures = append(item.Failures, msg)
+	}
+	for _, c := range result.Captures {
+		if c.Error != "" {
+			item.Failures = append(item.Failures, fmt.Sprintf("capture %s failed: %s", c.Name, c.Error))
+		}
+	}
+	for _, s := range result.Scripts {
+		if s.Error != "" && result.Error == "" {
+			item.Failures = append(item.Failures, fmt.Sprintf("@%s script %s failed: %s", s.Phase, s.Source, s.Error))
+		}
+	}
+	if len(result.Assertions) == 0 && item.Status >= 400 {
+		item.Failures = append(item.Failures, "status "+item.StatusText)
+	}
+
+	item.Passed = item.Error == "" && len(item.Failures) == 0
+	return item
+}
@@ -34,6 +34,12 @@ func LoadConfig(projectPath string) (models.HttxConfig, error) {
 	if config.Runner.MaxHistory == 0 {
 		config.Runner.MaxHistory = models.DefaultConfig.Runner.MaxHistory
 	}
+	if config.Runner.OnUnresolved == "" {
+		config.Runner.OnUnresolved = models.DefaultConfig.Runner.OnUnresolved
+	}
+	if config.Runner.ScriptTimeout == 0 {
+		config.Runner.ScriptTimeout = models.DefaultConfig.Runner.ScriptTimeout
+	}
 	if config.Output == "" {
 		config.Output = models.DefaultConfig.Output
 	}
@@ -0,0 +1,362 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cookie jars live in .carmelia/cookies/<env>.json, which ignores itself
+// in git like the runtime store.
+const cookiesDir = ".carmelia/cookies"
+
+var cookieMu sync.Mutex
+
+func cookiesPath(projectPath, envName string) string {
+	if envName == "" {
+		envName = noEnvRuntime
+	}
+	return filepath.Join(projectPath, cookiesDir, envName+".json")
+}
+
+// CookieJar is the http.CookieJar of a project env. Cookies set by
+// responses are written to the env's jar file at once, so they are shared
+// by every request of the env and kept across restarts. A detached jar
+// keeps its cookies in memory only.
+type CookieJar struct {
+	mu          sync.Mutex
+	projectPath string
+	envName     string
+	cookies     []models.JarCookie
+}
+
+// OpenCookieJar returns the cookie jar of an env, empty when its file
+// cannot be read.
+func OpenCookieJar(projectPath, envName string) *CookieJar {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+	cookies, _ := readCookies(projectPath, envName)
+	return &CookieJar{projectPath: projectPath, envName: envName, cookies: cookies}
+}
+
+// Detached returns an in-memory copy of the jar: it sends the same
+// cookies, and the cookies it receives are not saved.
+func (j *CookieJar) Detached() *CookieJar {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return &CookieJar{cookies: append([]models.JarCookie(nil), j.cookies...)}
+}
+
+// SetCookies stores the cookies of a response to u, following the
+// domain, path and expiry rules of RFC 6265.
+func (j *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host := cookieHost(u)
+	if host == "" || len(cookies) == 0 {
+		return
+	}
+	now := time.Now()
+
+	apply := func(list []models.JarCookie) []models.JarCookie {
+		for _, c := range cookies {
+			jc, keep, ok := jarCookie(c, u, host, now)
+			if !ok {
+				continue
+			}
+			list = removeCookie(list, jc.Domain, jc.Path, jc.Name)
+			if keep {
+				list = append(list, jc)
+			}
+		}
+		return list
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.projectPath == "" {
+		j.cookies = apply(j.cookies)
+		return
+	}
+
+	// Merge into the file rather than overwrite it with this jar's view,
+	// which may be older than another request's
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+	stored, err := readCookies(j.projectPath, j.envName)
+	if err != nil {
+		stored = j.cookies
+	}
+	j.cookies = apply(stored)
+	writeCookies(j.projectPath, j.envName, j.cookies)
+}
+
+// Cookies returns the cookies to send with a request to u.
+func (j *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	host := cookieHost(u)
+	if host == "" {
+		return nil
+	}
+	reqPath := u.EscapedPath()
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	now := time.Now().UnixMilli()
+
+	j.mu.Lock()
+	var matched []models.JarCookie
+	for _, c := range j.cookies {
+		switch {
+		case c.Expires != 0 && c.Expires <= now:
+		case c.Secure && u.Scheme != "https":
+		case c.HostOnly && host != c.Domain:
+		case !c.HostOnly && !domainMatch(host, c.Domain):
+		case !pathMatch(reqPath, c.Path):
+		default:
+			matched = append(matched, c)
+		}
+	}
+	j.mu.Unlock()
+
+	// Longer paths first, as RFC 6265 recommends
+	sort.SliceStable(matched, func(a, b int) bool { return len(matched[a].Path) > len(matched[b].Path) })
+	out := make([]*http.Cookie, len(matched))
+	for i, c := range matched {
+		out[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return out
+}
+
+// jarCookie converts a Set-Cookie of a response to u. keep is false when
+// the cookie deletes a stored one; ok is false when it must be ignored.
+func jarCookie(c *http.Cookie, u *url.URL, host string, now time.Time) (jc models.JarCookie, keep bool, ok bool) {
+	jc = models.JarCookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Path:     c.Path,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+		SameSite: sameSiteName(c.SameSite),
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+	switch {
+	case domain == "":
+		jc.Domain = host
+		jc.HostOnly = true
+	case domain == host:
+		jc.Domain = host
+	case net.ParseIP(host) == nil && domainMatch(host, domain) && strings.Contains(domain, "."):
+		jc.Domain = domain
+	default:
+		return jc, false, false
+	}
+
+	if !strings.HasPrefix(jc.Path, "/") {
+		jc.Path = defaultCookiePath(u)
+	}
+
+	switch {
+	case c.MaxAge < 0:
+		return jc, false, true
+	case c.MaxAge > 0:
+		jc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second).UnixMilli()
+	case !c.Expires.IsZero():
+		if !c.Expires.After(now) {
+			return jc, false, true
+		}
+		jc.Expires = c.Expires.UnixMilli()
+	}
+	return jc, true, true
+}
+
+func cookieHost(u *url.URL) string {
+	return strings.ToLower(u.Hostname())
+}
+
+// domainMatch reports whether host is domain or one of its subdomains.
+func domainMatch(host, domain string) bool {
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether a cookie path applies to a request path.
+func pathMatch(reqPath, cookiePath string) bool {
+	if reqPath == cookiePath || cookiePath == "/" {
+		return true
+	}
+	return strings.HasPrefix(reqPath, cookiePath) &&
+		(strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/')
+}
+
+// defaultCookiePath is the directory of the request path.
+func defaultCookiePath(u *url.URL) string {
+	p := u.EscapedPath()
+	if !strings.HasPrefix(p, "/") || strings.Count(p, "/") == 1 {
+		return "/"
+	}
+	return path.Dir(p)
+}
+
+func sameSiteName(s http.SameSite) string {
+	switch s {
+	case http.SameSiteLaxMode:
+		return "Lax"
+	case http.SameSiteStrictMode:
+		return "Strict"
+	case http.SameSiteNoneMode:
+		return "None"
+	}
+	return ""
+}
+
+func removeCookie(list []models.JarCookie, domain, path, name string) []models.JarCookie {
+	out := list[:0]
+	for _, c := range list {
+		if c.Domain != domain || c.Path != path || c.Name != name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// ListCookies returns the unexpired cookies of an env's jar, sorted by
+// domain, path and name.
+func ListCookies(projectPath, envName string) ([]models.JarCookie, error) {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+
+	cookies, err := readCookies(projectPath, envName)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	out := []models.JarCookie{}
+	for _, c := range cookies {
+		if c.Expires == 0 || c.Expires > now {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool {
+		if out[a].Domain != out[b].Domain {
+			return out[a].Domain < out[b].Domain
+		}
+		if out[a].Path != out[b].Path {
+			return out[a].Path < out[b].Path
+		}
+		return out[a].Name < out[b].Name
+	})
+	return out, nil
+}
+
+// SetCookie adds a cookie to an env's jar, or replaces the one with the
+// same domain, path and name.
+func SetCookie(projectPath, envName string, cookie models.JarCookie) error {
+	cookie.Name = strings.TrimSpace(cookie.Name)
+	cookie.Domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(cookie.Domain)), ".")
+	if cookie.Name == "" {
+		return fmt.Errorf("cookie name is required")
+	}
+	if cookie.Domain == "" {
+		return fmt.Errorf("cookie domain is required")
+	}
+	if !strings.HasPrefix(cookie.Path, "/") {
+		cookie.Path = "/"
+	}
+
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+	cookies, err := readCookies(projectPath, envName)
+	if err != nil {
+		return err
+	}
+	cookies = append(removeCookie(cookies, cookie.Domain, cookie.Path, cookie.Name), cookie)
+	return writeCookies(projectPath, envName, cookies)
+}
+
+// DeleteCookie removes one cookie from an env's jar.
+func DeleteCookie(projectPath, envName, domain, path, name string) error {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+
+	cookies, err := readCookies(projectPath, envName)
+	if err != nil {
+		return err
+	}
+	kept := removeCookie(cookies, domain, path, name)
+	if len(kept) == len(cookies) {
+		return nil
+	}
+	return writeCookies(projectPath, envName, kept)
+}
+
+// ClearCookies removes every cookie of an env's jar.
+func ClearCookies(projectPath, envName string) error {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+
+	if err := os.Remove(cookiesPath(projectPath, envName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear cookies: %w", err)
+	}
+	return nil
+}
+
+func renameCookies(projectPath, oldName, newName string) error {
+	cookieMu.Lock()
+	defer cookieMu.Unlock()
+
+	if err := os.Rename(cookiesPath(projectPath, oldName), cookiesPath(projectPath, newName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func readCookies(projectPath, envName string) ([]models.JarCookie, error) {
+	data, err := os.ReadFile(cookiesPath(projectPath, envName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cookies: %w", err)
+	}
+
+	var cookies []models.JarCookie
+	if err := json.Unmarshal(data, &cookies); err != nil {
+		return nil, fmt.Errorf("failed to parse cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+// writeCookies saves a jar, dropping expired cookies.
+func writeCookies(projectPath, envName string, cookies []models.JarCookie) error {
+	dir := filepath.Join(projectPath, cookiesDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create cookies dir: %w", err)
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := os.WriteFile(ignore, []byte("*\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ignore, err)
+		}
+	}
+
+	now := time.Now().UnixMilli()
+	kept := []models.JarCookie{}
+	for _, c := range cookies {
+		if c.Expires == 0 || c.Expires > now {
+			kept = append(kept, c)
+		}
+	}
+	data, err := json.MarshalIndent(kept, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cookies: %w", err)
+	}
+	return os.WriteFile(cookiesPath(projectPath, envName), data, 0o644)
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func mustParseURL(t *testing.T, raw string) *url.URL {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func cookieNames(cookies []*http.Cookie) []string {
+	names := []string{}
+	for _, c := range cookies {
+		names = append(names, c.Name+"="+c.Value)
+	}
+	return names
+}
+
+func TestDomainMatch(t *testing.T) {
+	tests := []struct {
+		host, domain string
+		want         bool
+	}{
+		{"example.com", "example.com", true},
+		{"api.example.com", "example.com", true},
+		{"a.b.example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com", "api.example.com", false},
+	}
+	for _, tt := range tests {
+		if got := domainMatch(tt.host, tt.domain); got != tt.want {
+			t.Errorf("domainMatch(%q, %q) = %v, want %v", tt.host, tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		reqPath, cookiePath string
+		want                bool
+	}{
+		{"/", "/", true},
+		{"/api/users", "/", true},
+		{"/api", "/api", true},
+		{"/api/users", "/api", true},
+		{"/api/users", "/api/", true},
+		{"/apiv2", "/api", false},
+		{"/", "/api", false},
+	}
+	for _, tt := range tests {
+		if got := pathMatch(tt.reqPath, tt.cookiePath); got != tt.want {
+			t.Errorf("pathMatch(%q, %q) = %v, want %v", tt.reqPath, tt.cookiePath, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultCookiePath(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://example.com", "/"},
+		{"https://example.com/login", "/"},
+		{"https://example.com/api/login", "/api"},
+		{"https://example.com/api/v1/login", "/api/v1"},
+	}
+	for _, tt := range tests {
+		if got := defaultCookiePath(mustParseURL(t, tt.url)); got != tt.want {
+			t.Errorf("defaultCookiePath(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestJarCookie(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	u := mustParseURL(t, "https://api.example.com/auth/login")
+	tests := []struct {
+		name   string
+		cookie http.Cookie
+		want   models.JarCookie
+		keep   bool
+		ok     bool
+	}{
+		{
+			name:   "host-only with default path",
+			cookie: http.Cookie{Name: "sid", Value: "1"},
+			want:   models.JarCookie{Name: "sid", Value: "1", Domain: "api.example.com", Path: "/auth", HostOnly: true},
+			keep:   true, ok: true,
+		},
+		{
+			name:   "parent domain",
+			cookie: http.Cookie{Name: "sid", Value: "1", Domain: ".Example.com", Path: "/", Secure: true, SameSite: http.SameSiteLaxMode},
+			want:   models.JarCookie{Name: "sid", Value: "1", Domain: "example.com", Path: "/", Secure: true, SameSite: "Lax"},
+			keep:   true, ok: true,
+		},
+		{
+			name:   "max-age",
+			cookie: http.Cookie{Name: "sid", Value: "1", Path: "/", MaxAge: 60},
+			want:   models.JarCookie{Name: "sid", Value: "1", Domain: "api.example.com", Path: "/", HostOnly: true, Expires: now.Add(time.Minute).UnixMilli()},
+			keep:   true, ok: true,
+		},
+		{
+			name:   "negative max-age deletes",
+			cookie: http.Cookie{Name: "sid", Path: "/", MaxAge: -1},
+			want:   models.JarCookie{Name: "sid", Domain: "api.example.com", Path: "/", HostOnly: true},
+			keep:   false, ok: true,
+		},
+		{
+			name:   "past expiry deletes",
+			cookie: http.Cookie{Name: "sid", Path: "/", Expires: now.Add(-time.Hour)},
+			want:   models.JarCookie{Name: "sid", Domain: "api.example.com", Path: "/", HostOnly: true},
+			keep:   false, ok: true,
+		},
+		{
+			name:   "unrelated domain is ignored",
+			cookie: http.Cookie{Name: "sid", Value: "1", Domain: "other.com"},
+			ok:     false,
+		},
+		{
+			name:   "top-level domain is ignored",
+			cookie: http.Cookie{Name: "sid", Value: "1", Domain: "com"},
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, keep, ok := jarCookie(&tt.cookie, u, cookieHost(u), now)
+			if ok != tt.ok || keep != tt.keep {
+				t.Fatalf("keep, ok = %v, %v; want %v, %v", keep, ok, tt.keep, tt.ok)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCookieJarDetached(t *testing.T) {
+	jar := (&CookieJar{}).Detached()
+	login := mustParseURL(t, "https://api.example.com/auth/login")
+	jar.SetCookies(login, []*http.Cookie{
+		{Name: "host", Value: "1"},
+		{Name: "domain", Value: "2", Domain: "example.com", Path: "/"},
+		{Name: "secure", Value: "3", Path: "/", Secure: true},
+		{Name: "deep", Value: "4", Path: "/auth/login"},
+	})
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{"https://api.example.com/auth/login", []string{"deep=4", "host=1", "domain=2", "secure=3"}},
+		{"https://api.example.com/auth", []string{"host=1", "domain=2", "secure=3"}},
+		{"http://api.example.com/", []string{"domain=2"}},
+		{"https://www.example.com/auth", []string{"domain=2"}},
+		{"https://other.com/", []string{}},
+	}
+	for _, tt := range tests {
+		got := cookieNames(jar.Cookies(mustParseURL(t, tt.url)))
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Cookies(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	// A deletion removes the cookie with the same domain, path and name
+	jar.SetCookies(login, []*http.Cookie{{Name: "domain", Domain: "example.com", Path: "/", MaxAge: -1}})
+	if got := cookieNames(jar.Cookies(mustParseURL(t, "https://www.example.com/"))); len(got) != 0 {
+		t.Errorf("after deletion: %v", got)
+	}
+}
+
+func TestCookieJarPersists(t *testing.T) {
+	dir := t.TempDir()
+	u := mustParseURL(t, "https://example.com/")
+
+	OpenCookieJar(dir, "dev").SetCookies(u, []*http.Cookie{{Name: "sid", Value: "1"}})
+	if _, err := os.Stat(filepath.Join(dir, cookiesDir, ".gitignore")); err != nil {
+		t.Errorf("jar dir is not ignored: %v", err)
+	}
+
+	// A second jar opened before the first one wrote merges into the file
+	other := OpenCookieJar(dir, "dev")
+	OpenCookieJar(dir, "dev").SetCookies(u, []*http.Cookie{{Name: "theme", Value: "dark"}})
+	other.SetCookies(u, []*http.Cookie{{Name: "lang", Value: "en"}})
+
+	cookies, err := ListCookies(dir, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range cookies {
+		names = append(names, c.Name)
+	}
+	if want := []string{"lang", "sid", "theme"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("stored cookies = %v, want %v", names, want)
+	}
+
+	if err := DeleteCookie(dir, "dev", "example.com", "/", "sid"); err != nil {
+		t.Fatal(err)
+	}
+	if got := cookieNames(OpenCookieJar(dir, "dev").Cookies(u)); !reflect.DeepEqual(got, []string{"theme=dark", "lang=en"}) {
+		t.Errorf("after delete: %v", got)
+	}
+	if got := cookieNames(OpenCookieJar(dir, "prod").Cookies(u)); len(got) != 0 {
+		t.Errorf("other env sees %v", got)
+	}
+
+	if err := ClearCookies(dir, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if cookies, _ := ListCookies(dir, "dev"); len(cookies) != 0 {
+		t.Errorf("after clear: %v", cookies)
+	}
+}
+
+func TestSetCookieValidates(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		cookie  models.JarCookie
+		wantErr bool
+	}{
+		{models.JarCookie{Name: "a", Value: "1", Domain: ".Example.com"}, false},
+		{models.JarCookie{Name: " ", Domain: "example.com"}, true},
+		{models.JarCookie{Name: "a"}, true},
+	}
+	for _, tt := range tests {
+		if err := SetCookie(dir, "dev", tt.cookie); (err != nil) != tt.wantErr {
+			t.Errorf("SetCookie(%+v) error = %v, wantErr %v", tt.cookie, err, tt.wantErr)
+		}
+	}
+	cookies, _ := ListCookies(dir, "dev")
+	want := []models.JarCookie{{Name: "a", Value: "1", Domain: "example.com", Path: "/"}}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Errorf("stored = %+v, want %+v", cookies, want)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LoadDataFile reads the rows of a data file for iterations: a CSV file
+// with a header row, or a JSON array of objects. Relative paths are
+// resolved against the project root. Every value is a string; nested JSON
+// values are kept as compact JSON, so `{{user.name}}` still reads into them.
+func LoadDataFile(projectPath, path string) ([]map[string]string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(projectPath, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data file: %w", err)
+	}
+
+	var rows []map[string]string
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+	if strings.EqualFold(filepath.Ext(path), ".json") || bytes.HasPrefix(trimmed, []byte("[")) {
+		rows, err = parseJSONRows(trimmed)
+	} else {
+		rows, err = parseCSVRows(trimmed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s has no rows", filepath.Base(path))
+	}
+	return rows, nil
+}
+
+func parseJSONRows(data []byte) ([]map[string]string, error) {
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("expected an array of objects: %w", err)
+	}
+
+	rows := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		row := make(map[string]string, len(item))
+		for key, raw := range item {
+			var s string
+			switch {
+			case json.Unmarshal(raw, &s) == nil:
+				row[key] = s
+			case string(raw) == "null":
+				row[key] = ""
+			default:
+				var buf bytes.Buffer
+				if err := json.Compact(&buf, raw); err != nil {
+					return nil, err
+				}
+				row[key] = buf.String()
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseCSVRows(data []byte) ([]map[string]string, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if name != "" && i < len(record) {
+				row[name] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseDotenv parses a .env file. It supports comments, `export KEY=...`,
+// single-quoted (literal), double-quoted (escapes, expansion) and
+// backtick-quoted values, all of which may span lines, unquoted values
+// with trailing ` # comments`, and ${VAR}, $VAR, ${VAR:-default} and
+// ${VAR-default} expansion. Variables are looked up among the keys defined
+// earlier in the file, then through lookup.
+func ParseDotenv(data string, lookup func(string) (string, bool)) (map[string]string, error) {
+	p := &dotenvParser{data: strings.ReplaceAll(data, "\r\n", "\n"), line: 1, values: map[string]string{}, lookup: lookup}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.values, nil
+}
+
+type dotenvParser struct {
+	data   string
+	pos    int
+	line   int
+	values map[string]string
+	lookup func(string) (string, bool)
+}
+
+func (p *dotenvParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *dotenvParser) parse() error {
+	for p.pos < len(p.data) {
+		p.skipBlank()
+		if p.pos >= len(p.data) {
+			break
+		}
+		switch p.data[p.pos] {
+		case '\n':
+			p.pos++
+			p.line++
+			continue
+		case '#':
+			p.skipLine()
+			continue
+		}
+
+		if strings.HasPrefix(p.data[p.pos:], "export ") || strings.HasPrefix(p.data[p.pos:], "export\t") {
+			p.pos += len("export")
+			p.skipBlank()
+		}
+
+		start := p.pos
+		for p.pos < len(p.data) && isDotenvKeyChar(p.data[p.pos]) {
+			p.pos++
+		}
+		key := p.data[start:p.pos]
+		if key == "" {
+			return p.errorf("expected a variable name")
+		}
+		p.skipBlank()
+		if p.pos >= len(p.data) || p.data[p.pos] != '=' {
+			// `export KEY` on its own only marks a variable for export
+			if p.pos >= len(p.data) || p.data[p.pos] == '\n' || p.data[p.pos] == '#' {
+				p.skipLine()
+				continue
+			}
+			return p.errorf("expected '=' after %s", key)
+		}
+		p.pos++
+		p.skipBlank()
+
+		value, err := p.value()
+		if err != nil {
+			return err
+		}
+		p.values[key] = value
+	}
+	return nil
+}
+
+func (p *dotenvParser) value() (string, error) {
+	if p.pos >= len(p.data) {
+		return "", nil
+	}
+	switch quote := p.data[p.pos]; quote {
+	case '\'', '`':
+		raw, err := p.quoted(quote)
+		if err != nil {
+			return "", err
+		}
+		p.skipLine()
+		return raw, nil
+	case '"':
+		raw, err := p.quoted(quote)
+		if err != nil {
+			return "", err
+		}
+		p.skipLine()
+		return p.expand(unescapeDotenv(raw)), nil
+	}
+
+	start := p.pos
+	for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		if p.data[p.pos] == '#' && p.pos > start && (p.data[p.pos-1] == ' ' || p.data[p.pos-1] == '\t') {
+			break
+		}
+		p.pos++
+	}
+	raw := strings.TrimSpace(p.data[start:p.pos])
+	p.skipLine()
+	return p.expand(raw), nil
+}
+
+// quoted returns the text up to the closing quote, which may be on a
+// later line.
+func (p *dotenvParser) quoted(quote byte) (string, error) {
+	startLine := p.line
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.data) {
+		ch := p.data[p.pos]
+		switch {
+		case ch == '\\' && quote == '"' && p.pos+1 < len(p.data):
+			b.WriteByte(ch)
+			b.WriteByte(p.data[p.pos+1])
+			p.pos += 2
+			continue
+		case ch == quote:
+			p.pos++
+			return b.String(), nil
+		case ch == '\n':
+			p.line++
+		}
+		b.WriteByte(ch)
+		p.pos++
+	}
+	return "", fmt.Errorf("line %d: unterminated %c quote", startLine, quote)
+}
+
+func (p *dotenvParser) skipBlank() {
+	for p.pos < len(p.data) && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// skipLine moves past the end of the current line, ignoring the rest.
+func (p *dotenvParser) skipLine() {
+	for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+		p.pos++
+	}
+	if p.pos < len(p.data) {
+		p.pos++
+		p.line++
+	}
+}
+
+// expand replaces ${VAR}, ${VAR:-default}, ${VAR-default} and $VAR. `\$`
+// (kept by unescapeDotenv as \x00$) stays a literal dollar.
+func (p *dotenvParser) expand(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if ch == '\x00' && i+1 < len(s) && s[i+1] == '$' {
+			b.WriteByte('$')
+			i++
+			continue
+		}
+		if ch != '$' || i+1 >= len(s) {
+			b.WriteByte(ch)
+			continue
+		}
+
+		if s[i+1] == '{' {
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				b.WriteByte(ch)
+				continue
+			}
+			expr := s[i+2 : i+end]
+			i += end
+
+			name, def, hasDefault, emptyIsUnset := expr, "", false, false
+			if n, d, ok := strings.Cut(expr, ":-"); ok {
+				name, def, hasDefault, emptyIsUnset = n, d, true, true
+			} else if n, d, ok := strings.Cut(expr, "-"); ok {
+				name, def, hasDefault = n, d, true
+			}
+			val, ok := p.get(name)
+			if hasDefault && (!ok || (emptyIsUnset && val == "")) {
+				val = p.expand(def)
+			}
+			b.WriteString(val)
+			continue
+		}
+
+		j := i + 1
+		for j < len(s) && isDotenvNameChar(s[j]) {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(ch)
+			continue
+		}
+		val, _ := p.get(s[i+1 : j])
+		b.WriteString(val)
+		i = j - 1
+	}
+	return b.String()
+}
+
+func (p *dotenvParser) get(name string) (string, bool) {
+	if val, ok := p.values[name]; ok {
+		return val, true
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+	return "", false
+}
+
+// unescapeDotenv handles the escapes of double-quoted values. An escaped
+// dollar is marked with a NUL byte so expansion leaves it alone.
+func unescapeDotenv(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case '$':
+			b.WriteString("\x00$")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func isDotenvKeyChar(ch byte) bool {
+	return isDotenvNameChar(ch) || ch == '.' || ch == '-'
+}
+
+func isDotenvNameChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// loadDotenvFiles parses dotenv files in order, later files overriding
+// earlier ones. Relative paths are resolved against dir, the directory of
+// the env file listing them, and missing files are skipped, since files
+// like .env.local are usually not committed. Expansion sees earlier files,
+// then the system environment.
+func loadDotenvFiles(dir string, paths []string) (map[string]string, error) {
+	merged := map[string]string{}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		values, err := ParseDotenv(string(data), func(name string) (string, bool) {
+			if val, ok := merged[name]; ok {
+				return val, true
+			}
+			return os.LookupEnv(name)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filepath.Base(path), err)
+		}
+		for k, v := range values {
+			merged[k] = v
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "HOME" {
+			return "/home/ada", true
+		}
+		return "", false
+	}
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "plain values and comments",
+			data: "# comment\nA=1\n  B = two words  \n\nC=x # trailing\nD=x#not-a-comment\nE=\n",
+			want: map[string]string{"A": "1", "B": "two words", "C": "x", "D": "x#not-a-comment", "E": ""},
+		},
+		{
+			name: "export",
+			data: "export A=1\nexport\tB=2\nexport A\nexported=3\n",
+			want: map[string]string{"A": "1", "B": "2", "exported": "3"},
+		},
+		{
+			name: "quotes",
+			data: "A='single # $HOME'\nB=\"double # $HOME\"\nC=`back $HOME`\nD=\"x\" # comment\n",
+			want: map[string]string{"A": "single # $HOME", "B": "double # /home/ada", "C": "back $HOME", "D": "x"},
+		},
+		{
+			name: "escapes in double quotes only",
+			data: `A="a\nb\tc\"d\\e\$HOME"` + "\n" + `B='a\nb'` + "\n",
+			want: map[string]string{"A": "a\nb\tc\"d\\e$HOME", "B": `a\nb`},
+		},
+		{
+			name: "multiline",
+			data: "KEY=\"-----BEGIN-----\nabc\n-----END-----\"\nNEXT='x\ny'\nLAST=1\r\n",
+			want: map[string]string{"KEY": "-----BEGIN-----\nabc\n-----END-----", "NEXT": "x\ny", "LAST": "1"},
+		},
+		{
+			name: "expansion",
+			data: "A=1\nB=${A}-$A-$HOME\nC=${MISSING:-def}\nD=${MISSING-def}\nE=\nF=${E:-def}/${E-def}\nG=$\nH=${unclosed\n",
+			want: map[string]string{"A": "1", "B": "1-1-/home/ada", "C": "def", "D": "def", "E": "", "F": "def/", "G": "$", "H": "${unclosed"},
+		},
+		{
+			name: "keys with dots and dashes",
+			data: "app.name=x\nlog-level=debug\n",
+			want: map[string]string{"app.name": "x", "log-level": "debug"},
+		},
+		{
+			name:    "unterminated quote",
+			data:    "A=1\nB=\"open\nstill open\n",
+			wantErr: "line 2: unterminated \" quote",
+		},
+		{
+			name:    "missing =",
+			data:    "A=1\nB 2\n",
+			wantErr: "line 2: expected '=' after B",
+		},
+		{
+			name:    "missing name",
+			data:    "=1\n",
+			wantErr: "line 1: expected a variable name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv(tt.data, lookup)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDotenv = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
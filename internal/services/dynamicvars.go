@@ -0,0 +1,279 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DynamicValues records the dynamic variables evaluated during one
+// execution, keyed by expression (e.g. "$uuid" or "$randomInt 1 10"). The
+// same expression used twice in a request resolves to the same value.
+type DynamicValues map[string]string
+
+// dynamicNow is the clock used by time-based dynamic variables.
+var dynamicNow = time.Now
+
+// evalDynamic evaluates a built-in `$name args...` expression. ok is false
+// for unknown names or invalid arguments, which leaves the placeholder as-is.
+func evalDynamic(expr string, opts ResolveOptions) (string, bool) {
+	if !strings.HasPrefix(expr, "$") {
+		return "", false
+	}
+	if opts.Dynamic != nil {
+		if val, ok := opts.Dynamic[expr]; ok {
+			return val, true
+		}
+	}
+
+	args := splitDynamicArgs(expr)
+	name, args := args[0], args[1:]
+
+	var val string
+	var err error
+	switch name {
+	case "$uuid", "$guid":
+		val, err = newUUID()
+	case "$timestamp":
+		var t time.Time
+		t, err = applyTimeOffset(dynamicNow(), args)
+		val = strconv.FormatInt(t.Unix(), 10)
+	case "$isoTimestamp":
+		var t time.Time
+		t, err = applyTimeOffset(dynamicNow(), args)
+		val = t.UTC().Format(time.RFC3339)
+	case "$randomInt":
+		val, err = randomInt(args)
+	case "$datetime":
+		val, err = formatDatetime(args)
+	case "$randomEmail":
+		var suffix string
+		suffix, err = randomHex(4)
+		val = "user_" + suffix + "@example.com"
+	case "$dotenv":
+		if len(args) != 1 {
+			return "", false
+		}
+		env, err := loadProjectDotenv(opts.ProjectPath)
+		if err != nil {
+			return "", false
+		}
+		v, found := env[args[0]]
+		if !found {
+			return "", false
+		}
+		val = v
+	default:
+		return "", false
+	}
+	if err != nil {
+		return "", false
+	}
+
+	// $dotenv reads a stable value, so it is not recorded
+	if opts.Dynamic != nil && name != "$dotenv" {
+		opts.Dynamic[expr] = val
+	}
+	return val, true
+}
+
+// splitDynamicArgs splits an expression on spaces, keeping quoted
+// arguments such as "YYYY-MM-DD HH:mm" together.
+func splitDynamicArgs(expr string) []string {
+	var args []string
+	var cur strings.Builder
+	quote := byte(0)
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '"' || ch == '\''):
+			quote = ch
+		case quote == 0 && (ch == ' ' || ch == '\t'):
+			if cur.Len() > 0 {
+				args = append(args, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteByte(ch)
+		}
+	}
+	if cur.Len() > 0 {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	return args
+}
+
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// randomInt returns an integer in [min, max). Defaults to [0, 1000).
+func randomInt(args []string) (string, error) {
+	lo, hi := int64(0), int64(1000)
+	var err error
+	if len(args) >= 1 {
+		if lo, err = strconv.ParseInt(args[0], 10, 64); err != nil {
+			return "", err
+		}
+	}
+	if len(args) >= 2 {
+		if hi, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return "", err
+		}
+	}
+	if hi <= lo {
+		return "", fmt.Errorf("$randomInt: max must be greater than min")
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(hi-lo))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(lo+n.Int64(), 10), nil
+}
+
+// formatDatetime implements `$datetime format [offset]`. format is
+// iso8601, rfc1123, unix or a pattern such as "YYYY-MM-DD HH:mm:ss".
+func formatDatetime(args []string) (string, error) {
+	format := "iso8601"
+	if len(args) > 0 {
+		format, args = args[0], args[1:]
+	}
+	t, err := applyTimeOffset(dynamicNow(), args)
+	if err != nil {
+		return "", err
+	}
+
+	switch strings.ToLower(format) {
+	case "iso8601":
+		return t.UTC().Format(time.RFC3339), nil
+	case "rfc1123":
+		return t.UTC().Format(http1123), nil
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	}
+	return formatPattern(t, format), nil
+}
+
+const http1123 = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+// applyTimeOffset shifts t by an offset written as `-1d`, `+2h`, `30m` or
+// the two-argument form `1 d`. Units: ms, s, m, h, d, w, M (months), y.
+func applyTimeOffset(t time.Time, args []string) (time.Time, error) {
+	if len(args) == 0 {
+		return t, nil
+	}
+	offset := strings.Join(args, "")
+
+	i := 0
+	if i < len(offset) && (offset[i] == '+' || offset[i] == '-') {
+		i++
+	}
+	for i < len(offset) && offset[i] >= '0' && offset[i] <= '9' {
+		i++
+	}
+	n, err := strconv.Atoi(offset[:i])
+	if err != nil {
+		return t, fmt.Errorf("invalid offset %q", offset)
+	}
+
+	switch offset[i:] {
+	case "ms":
+		return t.Add(time.Duration(n) * time.Millisecond), nil
+	case "s":
+		return t.Add(time.Duration(n) * time.Second), nil
+	case "m":
+		return t.Add(time.Duration(n) * time.Minute), nil
+	case "h":
+		return t.Add(time.Duration(n) * time.Hour), nil
+	case "d":
+		return t.AddDate(0, 0, n), nil
+	case "w":
+		return t.AddDate(0, 0, 7*n), nil
+	case "M":
+		return t.AddDate(0, n, 0), nil
+	case "y":
+		return t.AddDate(n, 0, 0), nil
+	}
+	return t, fmt.Errorf("invalid offset unit in %q", offset)
+}
+
+// datetimeTokens maps the moment.js-style tokens used in .http files to Go
+// layouts, longest first.
+var datetimeTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"DD", "02"}, {"D", "2"},
+	{"dddd", "Monday"}, {"ddd", "Mon"},
+	{"HH", "15"}, {"hh", "03"}, {"h", "3"},
+	{"mm", "04"}, {"ss", "05"}, {"SSS", ".000"},
+	{"A", "PM"}, {"ZZ", "-0700"}, {"Z", "-07:00"},
+}
+
+// formatPattern formats t with a moment.js-style pattern. Each token is
+// formatted on its own, so the other characters are copied as they are
+// even when they mean something in a Go layout (`1`, `Jan`, `PM`...);
+// text in square brackets is copied without looking for tokens.
+func formatPattern(t time.Time, format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		if format[i] == '[' {
+			if end := strings.IndexByte(format[i:], ']'); end > 0 {
+				b.WriteString(format[i+1 : i+end])
+				i += end + 1
+				continue
+			}
+		}
+		matched := false
+		for _, tok := range datetimeTokens {
+			if strings.HasPrefix(format[i:], tok.token) {
+				// Go only formats fractional seconds after a dot
+				b.WriteString(strings.TrimPrefix(t.Format(tok.layout), "."))
+				i += len(tok.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// loadProjectDotenv reads the project's .env file.
+func loadProjectDotenv(projectPath string) (map[string]string, error) {
+	if projectPath == "" {
+		return nil, fmt.Errorf("no project selected")
+	}
+	data, err := os.ReadFile(filepath.Join(projectPath, ".env"))
+	if err != nil {
+		return nil, err
+	}
+	return ParseDotenv(string(data), os.LookupEnv)
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func fixedNow(t *testing.T, now time.Time) {
+	t.Helper()
+	prev := dynamicNow
+	dynamicNow = func() time.Time { return now }
+	t.Cleanup(func() { dynamicNow = prev })
+}
+
+func TestFormatPattern(t *testing.T) {
+	at := time.Date(2024, time.March, 5, 14, 7, 9, 42_000_000, time.FixedZone("", 2*3600))
+	tests := []struct{ format, want string }{
+		{"YYYY-MM-DD", "2024-03-05"},
+		{"YY/M/D", "24/3/5"},
+		{"dddd, MMMM D", "Tuesday, March 5"},
+		{"ddd MMM", "Tue Mar"},
+		{"HH:mm:ss.SSS", "14:07:09.042"},
+		{"h:mm A", "2:07 PM"},
+		{"hh Z ZZ", "02 +02:00 +0200"},
+		{"YYYY-MM-DD[T]HH:mm", "2024-03-05T14:07"},
+		{"[Today is] dddd", "Today is Tuesday"},
+		{"[unclosed", "[unclosed"},
+		// Characters that mean something in a Go layout stay literal
+		{"1 Jan 2006 -07 .000", "1 Jan 2006 -07 .000"},
+	}
+	for _, tt := range tests {
+		if got := formatPattern(at, tt.format); got != tt.want {
+			t.Errorf("formatPattern(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
+
+func TestApplyTimeOffset(t *testing.T) {
+	base := time.Date(2024, time.January, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		args    []string
+		want    time.Time
+		wantErr bool
+	}{
+		{nil, base, false},
+		{[]string{"500ms"}, base.Add(500 * time.Millisecond), false},
+		{[]string{"-30s"}, base.Add(-30 * time.Second), false},
+		{[]string{"+15m"}, base.Add(15 * time.Minute), false},
+		{[]string{"2h"}, base.Add(2 * time.Hour), false},
+		{[]string{"-1", "d"}, base.AddDate(0, 0, -1), false},
+		{[]string{"1w"}, base.AddDate(0, 0, 7), false},
+		{[]string{"1M"}, base.AddDate(0, 1, 0), false},
+		{[]string{"-2y"}, base.AddDate(-2, 0, 0), false},
+		{[]string{"d"}, base, true},
+		{[]string{"1x"}, base, true},
+	}
+	for _, tt := range tests {
+		got, err := applyTimeOffset(base, tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("applyTimeOffset(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !got.Equal(tt.want) {
+			t.Errorf("applyTimeOffset(%q) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestEvalDynamic(t *testing.T) {
+	fixedNow(t, time.Date(2024, time.March, 5, 14, 7, 9, 0, time.UTC))
+	tests := []struct {
+		expr string
+		want string
+		ok   bool
+	}{
+		{"$timestamp", "1709647629", true},
+		{"$timestamp -1h", "1709644029", true},
+		{"$isoTimestamp", "2024-03-05T14:07:09Z", true},
+		{"$isoTimestamp 1 d", "2024-03-06T14:07:09Z", true},
+		{"$isoTimestamp 1x", "", false},
+		{"$datetime", "2024-03-05T14:07:09Z", true},
+		{"$datetime rfc1123", "Tue, 05 Mar 2024 14:07:09 GMT", true},
+		{"$datetime unix -1m", "1709647569", true},
+		{`$datetime "YYYY-MM-DD HH:mm" +1d`, "2024-03-06 14:07", true},
+		{"$datetime iso8601 1x", "", false},
+		{"$randomInt 5 4", "", false},
+		{"$nope", "", false},
+		{"name", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := evalDynamic(tt.expr, ResolveOptions{})
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("evalDynamic(%q) = %q, %v; want %q, %v", tt.expr, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEvalDynamicRecordsValues(t *testing.T) {
+	opts := ResolveOptions{Dynamic: DynamicValues{}}
+	first, ok := evalDynamic("$uuid", opts)
+	if !ok || !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(first) {
+		t.Fatalf("$uuid = %q, %v", first, ok)
+	}
+	if again, _ := evalDynamic("$uuid", opts); again != first {
+		t.Errorf("$uuid changed within one execution: %q then %q", first, again)
+	}
+	if opts.Dynamic["$uuid"] != first {
+		t.Errorf("recorded values = %v", opts.Dynamic)
+	}
+
+	n, ok := evalDynamic("$randomInt 3 4", opts)
+	if !ok || n != "3" {
+		t.Errorf("$randomInt 3 4 = %q, %v", n, ok)
+	}
+}
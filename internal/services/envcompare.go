@@ -0,0 +1,228 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CompareEnvs checks the environments of a project against each other and
+// against the variables used under .carmelia/requests/. The columns of
+// dataFiles, the data files requests are run with, count as defined.
+func CompareEnvs(projectPath string, dataFiles []string) (models.EnvComparison, error) {
+	names, err := ListEnvs(projectPath)
+	if err != nil {
+		return models.EnvComparison{}, err
+	}
+	columns := map[string]bool{}
+	for _, path := range dataFiles {
+		rows, err := LoadDataFile(projectPath, path)
+		if err != nil {
+			return models.EnvComparison{}, err
+		}
+		for _, row := range rows {
+			for column := range row {
+				columns[column] = true
+			}
+		}
+	}
+
+	cmp := models.EnvComparison{
+		Envs:       []string{},
+		Keys:       []string{},
+		Missing:    map[string][]string{},
+		Unresolved: map[string][]models.EnvSystemRef{},
+		Undefined:  []models.VariableUsage{},
+	}
+
+	defined := map[string]map[string]bool{} // env → key → is an object
+	all := map[string]bool{}
+	for _, name := range names {
+		vars, err := LoadEnv(projectPath, name)
+		if err != nil {
+			if cmp.Errors == nil {
+				cmp.Errors = map[string]string{}
+			}
+			cmp.Errors[name] = err.Error()
+			continue
+		}
+		cmp.Envs = append(cmp.Envs, name)
+
+		keys := map[string]bool{}
+		for _, key := range sortedKeys(vars) {
+			flattenEnvKey(keys, key, vars[key])
+			for _, m := range sysEnvRegex.FindAllStringSubmatch(vars[key], -1) {
+				cmp.Unresolved[name] = append(cmp.Unresolved[name], models.EnvSystemRef{Key: key, Variable: m[1]})
+			}
+		}
+		defined[name] = keys
+		for key := range keys {
+			all[key] = true
+		}
+	}
+
+	cmp.Keys = sortedKeys(all)
+	for _, name := range cmp.Envs {
+		for _, key := range cmp.Keys {
+			if _, ok := defined[name][key]; !ok {
+				cmp.Missing[name] = append(cmp.Missing[name], key)
+			}
+		}
+	}
+
+	usages, err := collectVariableUsages(projectPath, columns)
+	if err != nil {
+		return cmp, err
+	}
+	for _, usage := range usages {
+		for _, name := range cmp.Envs {
+			if !envDefines(defined[name], usage.Name) {
+				usage.MissingIn = append(usage.MissingIn, name)
+			}
+		}
+		if len(usage.MissingIn) > 0 {
+			cmp.Undefined = append(cmp.Undefined, usage)
+		}
+	}
+	return cmp, nil
+}
+
+// flattenEnvKey records key and, when its value is a JSON object, the
+// dotted paths below it. Lists are not expanded.
+func flattenEnvKey(keys map[string]bool, key, value string) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "{") {
+		keys[key] = false
+		return
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal([]byte(trimmed), &obj) != nil {
+		keys[key] = false
+		return
+	}
+	keys[key] = true
+	for child, raw := range obj {
+		flattenEnvKey(keys, key+"."+child, string(raw))
+	}
+}
+
+// envDefines reports whether a variable, possibly a path such as
+// `db.host` or `users[0].id`, is defined by an env's keys. The longest
+// defined prefix decides: an object's children are all known, anything
+// else may hold the rest of the path.
+func envDefines(keys map[string]bool, name string) bool {
+	for end := len(name); end > 0; end = strings.LastIndexAny(name[:end], ".[") {
+		if isObject, ok := keys[name[:end]]; ok {
+			return end == len(name) || !isObject
+		}
+	}
+	return false
+}
+
+// collectVariableUsages finds every {{variable}} in the project's .http
+// files that an env has to provide. File variables, response references,
+// dynamic variables, ${VAR}, {{body}} and the names in provided are
+// skipped, as are variables set by the scripts of any request: those are
+// kept for the following runs.
+func collectVariableUsages(projectPath string, provided map[string]bool) ([]models.VariableUsage, error) {
+	tree, err := BuildFileTree(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := requestFilePaths(tree)
+	contents := make(map[string]string, len(paths))
+	skip := map[string]bool{"body": true}
+	for name := range provided {
+		skip[name] = true
+	}
+	for _, path := range paths {
+		content, err := ReadRequest(projectPath, path)
+		if err != nil {
+			continue
+		}
+		contents[path] = content
+		for _, req := range ParseHttpFileAll(content) {
+			for name := range ScriptSetNames(req.Scripts, ScriptDir(projectPath, path)) {
+				skip[name] = true
+			}
+		}
+	}
+
+	var usages []models.VariableUsage
+	for _, path := range paths {
+		content, ok := contents[path]
+		if !ok {
+			continue
+		}
+		requests := ParseHttpFileAll(content)
+		fileVars := map[string]bool{}
+		for _, v := range collectFileVariables(splitRequestBlocks(content)) {
+			fileVars[v.Name] = true
+		}
+
+		seen := map[string]bool{}
+		for i, line := range strings.Split(content, "\n") {
+			lineNo := i + 1
+			for _, m := range placeholderRegex.FindAllStringSubmatch(line, -1) {
+				expr := m[1]
+				if expr == "" {
+					expr = m[2]
+				}
+				name := strings.TrimSpace(splitPipes(expr)[0])
+				if name == "" || strings.HasPrefix(name, "$") || strings.HasPrefix(name, "'") || strings.HasPrefix(name, "\"") ||
+					responseRefRegex.MatchString(name) || fileVars[name] || fileVars[pathHead(name)] || skip[name] || skip[pathHead(name)] {
+					continue
+				}
+				key := fmt.Sprintf("%s:%d", name, lineNo)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				usages = append(usages, models.VariableUsage{
+					Name:    name,
+					Path:    path,
+					Line:    lineNo,
+					Request: requestAtLine(requests, lineNo),
+				})
+			}
+		}
+	}
+	return usages, nil
+}
+
+// pathHead returns the variable name of `name.path` or `name[0]`.
+func pathHead(name string) string {
+	if i := strings.IndexAny(name, ".["); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// requestAtLine names the request covering a line: its `# @name`, or its
+// index as `#n`.
+func requestAtLine(requests []models.ParsedHttpRequest, line int) string {
+	for _, req := range requests {
+		if line >= req.StartLine && line <= req.EndLine {
+			if req.Name != "" {
+				return req.Name
+			}
+			return fmt.Sprintf("#%d", req.Index)
+		}
+	}
+	return ""
+}
+
+// requestFilePaths lists the .http files of a file tree, in tree order.
+func requestFilePaths(nodes []models.FileTreeNode) []string {
+	var paths []string
+	for _, node := range nodes {
+		if node.IsDir {
+			paths = append(paths, requestFilePaths(node.Children)...)
+		} else {
+			paths = append(paths, node.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompareEnvsUndefined(t *testing.T) {
+	dir := writeEnvs(t, map[string]string{
+		"dev.yaml":  "base_url: http://dev\nkey: k\n",
+		"prod.yaml": "base_url: http://prod\n",
+	})
+	files := map[string]string{
+		"auth.http": "# @name login\n# @post {%\n#   vars.set(\"token\", response.json().token)\n# %}\nPOST {{base_url}}/login\n",
+		"orders/create.http": "# @pre sign.js\n" +
+			"POST {{base_url}}/orders\n" +
+			"Authorization: Bearer {{token}}\n" +
+			"X-Signature: {{body | hmac 'sha256' key}}\n" +
+			"X-Ts: {{ts}}\n" +
+			"\n" +
+			"{\"tenant\": \"{{tenant.id}}\", \"note\": \"{{note}}\"}\n",
+		"orders/sign.js": "vars.set('ts', String(Date.now()))\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, ".carmelia", "requests", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tenants.json"), []byte(`[{"tenant": {"id": "t1"}}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmp, err := CompareEnvs(dir, []string{"tenants.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// {{body}}, script-set variables and data columns are not reported
+	var got []string
+	for _, usage := range cmp.Undefined {
+		got = append(got, usage.Name)
+	}
+	if want := []string{"note"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("undefined = %v, want %v", got, want)
+	}
+}
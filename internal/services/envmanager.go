@@ -1,6 +1,7 @@
 package services
 
 import (
+	"bytes"
 	"fmt"
 	"carmelia-desktop/internal/models"
 	"os"
@@ -13,6 +14,18 @@ import (
 
 const envsDir = ".carmelia/envs"
 
+// sharedEnvName is the implicit base of every env that does not extend
+// another one explicitly.
+const sharedEnvName = "_shared"
+
+// Reserved keys of an env file. They configure the env itself and are not
+// exposed as variables.
+const (
+	envExtendsKey  = "extends"
+	envAbstractKey = "abstract"
+	envDotenvKey   = "dotenv"
+)
+
 var sysEnvRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
 
 func GetEnvsDir(projectPath string) string {
@@ -30,11 +43,21 @@ func ListEnvs(projectPath string) ([]string, error) {
 		return nil, fmt.Errorf("failed to read envs dir: %w", err)
 	}
 
+	// Base envs (`_shared`, `_base`...) and envs marked `abstract: true`
+	// only exist to be extended
 	envs := []string{}
+	seen := map[string]bool{}
 	for _, entry := range entries {
 		name := entry.Name()
 		if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
 			envName := strings.TrimSuffix(strings.TrimSuffix(name, ".yaml"), ".yml")
+			if seen[envName] || strings.HasPrefix(envName, "_") {
+				continue
+			}
+			seen[envName] = true
+			if file, err := readEnvFile(projectPath, envName); err == nil && file.abstract {
+				continue
+			}
 			envs = append(envs, envName)
 		}
 	}
@@ -42,49 +65,266 @@ func ListEnvs(projectPath string) ([]string, error) {
 	return envs, nil
 }
 
+// LoadEnv returns the variables of an env merged over the envs it extends.
+// An env inherits from `extends: <name>` (or a list of names, later ones
+// winning), otherwise from `_shared` when that file exists. Within one env,
+// values from its `dotenv:` files come first, then its own keys, then its
+// unlocked secrets.
 func LoadEnv(projectPath, envName string) (models.EnvVariables, error) {
-	envPath := filepath.Join(GetEnvsDir(projectPath), envName+".yaml")
-
-	data, err := os.ReadFile(envPath)
+	raw, err := loadEnvChain(projectPath, envName, nil)
 	if err != nil {
-		// Try .yml extension
-		envPath = filepath.Join(GetEnvsDir(projectPath), envName+".yml")
-		data, err = os.ReadFile(envPath)
-		if err != nil {
-			return nil, fmt.Errorf("environment %q not found", envName)
-		}
-	}
-
-	var raw map[string]interface{}
-	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse env file: %w", err)
+		return nil, err
 	}
 
 	variables := models.EnvVariables{}
 	for key, value := range raw {
-		strVal := fmt.Sprintf("%v", value)
-		variables[key] = resolveSystemEnvVars(strVal)
+		variables[key] = resolveSystemEnvVars(value)
 	}
 
 	return variables, nil
 }
 
+// SaveEnv writes the variables of an env that differ from what it inherits
+// or reads from its dotenv files. The file is edited in place: reserved
+// keys, comments, key order and scalar types are kept, and new keys are
+// appended. Values that still match the expansion of a ${VAR} already in
+// the file keep the reference.
 func SaveEnv(projectPath, envName string, variables models.EnvVariables) error {
 	dir := GetEnvsDir(projectPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
 		return fmt.Errorf("failed to create envs dir: %w", err)
 	}
 
-	envPath := filepath.Join(dir, envName+".yaml")
-
-	data, err := yaml.Marshal(variables)
+	existing, err := readEnvFile(projectPath, envName)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	inherited, err := loadEnvBase(projectPath, envName, existing, nil)
 	if err != nil {
+		return err
+	}
+
+	// Secrets merged by LoadEnv are saved through SaveSecrets only. A value
+	// that came from a secret keeps whatever the file has under its name.
+	secrets := unlockedSecretValues(projectPath, envName)
+
+	own := models.EnvVariables{}
+	for key, value := range variables {
+		if secret, ok := secrets[key]; ok && secret == value {
+			if raw, ok := existing.vars[key]; ok {
+				own[key] = raw
+			}
+			continue
+		}
+		if raw, ok := existing.vars[key]; ok && resolveSystemEnvVars(raw) == value {
+			own[key] = raw
+			continue
+		}
+		if raw, ok := inherited[key]; ok && (raw == value || resolveSystemEnvVars(raw) == value) {
+			continue
+		}
+		own[key] = value
+	}
+
+	root := existing.root
+	if root == nil {
+		root = &yaml.Node{Kind: yaml.MappingNode}
+		existing.doc = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	}
+	content := make([]*yaml.Node, 0, len(root.Content))
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		if !isReservedEnvKey(key.Value) {
+			v, ok := own[key.Value]
+			if !ok {
+				continue
+			}
+			setYAMLValue(value, v)
+			delete(own, key.Value)
+		}
+		content = append(content, key, value)
+	}
+	for _, key := range sortedKeys(own) {
+		content = append(content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, newYAMLValue(own[key], nil))
+	}
+	root.Content = content
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(existing.doc); err != nil {
 		return fmt.Errorf("failed to marshal env: %w", err)
 	}
+	data := buf.Bytes()
+	if len(root.Content) == 0 {
+		data = []byte("{}\n")
+	}
 
+	envPath := filepath.Join(dir, envName+".yaml")
+	if path, ok := envFilePath(projectPath, envName); ok {
+		envPath = path
+	}
 	return os.WriteFile(envPath, data, 0o644)
 }
 
+// SetEnvValues stores values as an env's own keys, keeping the rest of the
+// file as it is.
+func SetEnvValues(projectPath, envName string, values map[string]string) error {
+	variables, err := LoadEnv(projectPath, envName)
+	if err != nil {
+		return err
+	}
+	for key, value := range values {
+		variables[key] = value
+	}
+	return SaveEnv(projectPath, envName, variables)
+}
+
+// envFile is an env file as written, before inheritance is applied. doc
+// and root keep the parsed YAML for in-place saves; both are nil when the
+// file does not exist. dir is the directory relative dotenv paths are
+// resolved against, the one holding the file.
+type envFile struct {
+	dir      string
+	extends  []string
+	abstract bool
+	dotenv   []string
+	vars     models.EnvVariables
+	doc      *yaml.Node
+	root     *yaml.Node
+}
+
+func isReservedEnvKey(key string) bool {
+	return key == envExtendsKey || key == envAbstractKey || key == envDotenvKey
+}
+
+// envFilePath returns the .yaml or .yml file of an env.
+func envFilePath(projectPath, envName string) (string, bool) {
+	for _, ext := range []string{".yaml", ".yml"} {
+		path := filepath.Join(GetEnvsDir(projectPath), envName+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func readEnvFile(projectPath, envName string) (envFile, error) {
+	path, ok := envFilePath(projectPath, envName)
+	if !ok {
+		return envFile{vars: models.EnvVariables{}}, os.ErrNotExist
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return envFile{vars: models.EnvVariables{}}, err
+	}
+
+	file := envFile{dir: filepath.Dir(path), vars: models.EnvVariables{}, doc: &yaml.Node{}}
+	if err := yaml.Unmarshal(data, file.doc); err != nil {
+		return envFile{vars: models.EnvVariables{}}, fmt.Errorf("failed to parse env file %s: %w", filepath.Base(path), err)
+	}
+	if len(file.doc.Content) == 0 {
+		// Empty file
+		file.doc = nil
+		return file, nil
+	}
+	file.root = file.doc.Content[0]
+	if file.root.Kind != yaml.MappingNode {
+		return envFile{vars: models.EnvVariables{}}, fmt.Errorf("failed to parse env file %s: expected a mapping", filepath.Base(path))
+	}
+
+	for i := 0; i+1 < len(file.root.Content); i += 2 {
+		key, value := file.root.Content[i].Value, file.root.Content[i+1]
+		switch key {
+		case envExtendsKey:
+			file.extends = stringList(value)
+		case envDotenvKey:
+			file.dotenv = stringList(value)
+		case envAbstractKey:
+			value.Decode(&file.abstract)
+		default:
+			file.vars[key] = yamlValueString(value)
+		}
+	}
+	return file, nil
+}
+
+// loadEnvChain returns the raw variables of envName over those of its
+// parents. visiting holds the envs being loaded below, to detect cycles.
+func loadEnvChain(projectPath, envName string, visiting []string) (models.EnvVariables, error) {
+	for _, name := range visiting {
+		if name == envName {
+			return nil, fmt.Errorf("environment inheritance cycle: %s", strings.Join(append(visiting, envName), " → "))
+		}
+	}
+
+	file, err := readEnvFile(projectPath, envName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("environment %q not found", envName)
+		}
+		return nil, err
+	}
+
+	variables, err := loadEnvBase(projectPath, envName, file, visiting)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range file.vars {
+		variables[key] = value
+	}
+	for key, value := range unlockedSecretValues(projectPath, envName) {
+		variables[key] = value
+	}
+	return variables, nil
+}
+
+// loadEnvBase merges what an env's own keys override: the variables it
+// inherits, then those of its dotenv files.
+func loadEnvBase(projectPath, envName string, file envFile, visiting []string) (models.EnvVariables, error) {
+	parents := file.extends
+	if len(parents) == 0 && envName != sharedEnvName {
+		if _, ok := envFilePath(projectPath, sharedEnvName); ok {
+			parents = []string{sharedEnvName}
+		}
+	}
+
+	variables := models.EnvVariables{}
+	for _, parent := range parents {
+		inherited, err := loadEnvChain(projectPath, parent, append(visiting, envName))
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range inherited {
+			variables[key] = value
+		}
+	}
+
+	dotenv, err := loadDotenvFiles(file.dir, file.dotenv)
+	if err != nil {
+		return nil, fmt.Errorf("environment %q: %w", envName, err)
+	}
+	for key, value := range dotenv {
+		variables[key] = value
+	}
+	return variables, nil
+}
+
+// stringList reads a YAML value that is either a string or a list.
+func stringList(node *yaml.Node) []string {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return []string{node.Value}
+	case yaml.SequenceNode:
+		out := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			out = append(out, item.Value)
+		}
+		return out
+	}
+	return nil
+}
+
 func RenameEnv(projectPath, oldName, newName string) error {
 	dir := GetEnvsDir(projectPath)
 	oldPath := filepath.Join(dir, oldName+".yaml")
@@ -95,7 +335,16 @@ func RenameEnv(projectPath, oldName, newName string) error {
 		}
 	}
 	newPath := filepath.Join(dir, newName+".yaml")
-	return os.Rename(oldPath, newPath)
+	if err := os.Rename(oldPath, newPath); err != nil {
+		return err
+	}
+	if err := renameSecrets(projectPath, oldName, newName); err != nil {
+		return err
+	}
+	if err := renameRuntime(projectPath, oldName, newName); err != nil {
+		return err
+	}
+	return renameCookies(projectPath, oldName, newName)
 }
 
 func resolveSystemEnvVars(value string) string {
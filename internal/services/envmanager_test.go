@@ -0,0 +1,200 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeEnvs creates a project whose env files have the given contents,
+// keyed by file name.
+func writeEnvs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(GetEnvsDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(GetEnvsDir(dir), name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadEnvInheritance(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		env     string
+		want    models.EnvVariables
+		wantErr string
+	}{
+		{
+			name:  "own values only",
+			files: map[string]string{"dev.yaml": "host: dev.local\n"},
+			env:   "dev",
+			want:  models.EnvVariables{"host": "dev.local"},
+		},
+		{
+			name: "_shared is the implicit base",
+			files: map[string]string{
+				"_shared.yaml": "host: shared\ntimeout: \"30\"\n",
+				"dev.yml":      "host: dev.local\n",
+			},
+			env:  "dev",
+			want: models.EnvVariables{"host": "dev.local", "timeout": "30"},
+		},
+		{
+			name: "extends reaches _shared through the root parent",
+			files: map[string]string{
+				"_shared.yaml": "host: shared\nshared: \"yes\"\n",
+				"_base.yaml":   "host: base\nretries: \"3\"\n",
+				"prod.yaml":    "extends: _base\n",
+			},
+			env:  "prod",
+			want: models.EnvVariables{"host": "base", "retries": "3", "shared": "yes"},
+		},
+		{
+			name: "later parents win",
+			files: map[string]string{
+				"a.yaml":   "x: a\ny: a\n",
+				"b.yaml":   "x: b\n",
+				"dev.yaml": "extends: [a, b]\n",
+			},
+			env:  "dev",
+			want: models.EnvVariables{"x": "b", "y": "a"},
+		},
+		{
+			name: "chains inherit _shared at the root",
+			files: map[string]string{
+				"_shared.yaml":  "a: shared\nb: shared\nc: shared\n",
+				"staging.yaml":  "b: staging\nc: staging\n",
+				"preprod.yaml":  "extends: staging\nc: preprod\n",
+				"abstract.yaml": "abstract: true\n",
+			},
+			env:  "preprod",
+			want: models.EnvVariables{"a": "shared", "b": "staging", "c": "preprod"},
+		},
+		{
+			name:    "missing env",
+			files:   map[string]string{},
+			env:     "dev",
+			wantErr: `environment "dev" not found`,
+		},
+		{
+			name:    "missing parent",
+			files:   map[string]string{"dev.yaml": "extends: nope\n"},
+			env:     "dev",
+			wantErr: `environment "nope" not found`,
+		},
+		{
+			name:    "self extension",
+			files:   map[string]string{"dev.yaml": "extends: dev\n"},
+			env:     "dev",
+			wantErr: "environment inheritance cycle: dev → dev",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"a.yaml": "extends: b\n",
+				"b.yaml": "extends: c\n",
+				"c.yaml": "extends: a\n",
+			},
+			env:     "a",
+			wantErr: "environment inheritance cycle: a → b → c → a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadEnv(writeEnvs(t, tt.files), tt.env)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadEnv = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListEnvsHidesBases(t *testing.T) {
+	dir := writeEnvs(t, map[string]string{
+		"_shared.yaml":   "a: \"1\"\n",
+		"_base.yaml":     "a: \"1\"\n",
+		"template.yaml":  "abstract: true\n",
+		"dev.yaml":       "extends: template\n",
+		"prod.yml":       "a: \"2\"\n",
+		"notes.txt":      "not an env",
+		"staging.yaml":   "a: \"3\"\n",
+		"staging.yml":    "a: \"4\"\n",
+		"disabled.yaml~": "a: \"5\"\n",
+	})
+	got, err := ListEnvs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"dev", "prod", "staging"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ListEnvs = %v, want %v", got, want)
+	}
+}
+
+func TestSaveEnvKeepsInheritance(t *testing.T) {
+	dir := writeEnvs(t, map[string]string{
+		"_base.yaml":   "host: base\nretries: \"3\"\n",
+		"staging.yaml": "extends: _base\nhost: staging\n",
+	})
+	vars, err := LoadEnv(dir, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars["token"] = "abc"
+	vars["host"] = "staging2"
+	if err := SaveEnv(dir, "staging", vars); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := readEnvFile(dir, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"_base"}; !reflect.DeepEqual(file.extends, want) {
+		t.Errorf("extends = %v, want %v", file.extends, want)
+	}
+	// Inherited values that did not change are not copied into the file
+	if want := (models.EnvVariables{"host": "staging2", "token": "abc"}); !reflect.DeepEqual(file.vars, want) {
+		t.Errorf("own values = %v, want %v", file.vars, want)
+	}
+	if got, _ := LoadEnv(dir, "staging"); !reflect.DeepEqual(got, vars) {
+		t.Errorf("reloaded = %v, want %v", got, vars)
+	}
+}
+
+func TestLoadEnvDotenvRelativeToEnvFile(t *testing.T) {
+	dir := writeEnvs(t, map[string]string{
+		"local.yaml": "dotenv: [../../.env, local.env, .env.missing]\n",
+	})
+	write := func(path, content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, ".env"), "TOKEN=root\nREGION=eu\n")
+	write(filepath.Join(GetEnvsDir(dir), "local.env"), "TOKEN=local\n")
+
+	got, err := LoadEnv(dir, "local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["TOKEN"] != "local" || got["REGION"] != "eu" {
+		t.Errorf("LoadEnv = %v, want TOKEN from local.env and REGION from the project .env", got)
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Env values are exposed as strings: scalars as written, nested objects and
+// lists as compact JSON (key order kept), so `{{db}}` inserts the object
+// and `{{db.host}}` reads into it. Saving goes back through the yaml.Node
+// tree, keeping comments, key order and scalar types.
+
+// yamlValueString returns the variable value of an env YAML node.
+func yamlValueString(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValueString(node.Alias)
+	case yaml.ScalarNode:
+		if node.ShortTag() == "!!null" {
+			return ""
+		}
+		return node.Value
+	}
+	return yamlNodeJSON(node)
+}
+
+// yamlNodeJSON renders a YAML node as compact JSON.
+func yamlNodeJSON(node *yaml.Node) string {
+	var b strings.Builder
+	writeYAMLNodeJSON(&b, node)
+	return b.String()
+}
+
+func writeYAMLNodeJSON(b *strings.Builder, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			writeYAMLNodeJSON(b, node.Content[0])
+		}
+	case yaml.AliasNode:
+		writeYAMLNodeJSON(b, node.Alias)
+	case yaml.MappingNode:
+		b.WriteByte('{')
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(quoteJSON(node.Content[i].Value))
+			b.WriteByte(':')
+			writeYAMLNodeJSON(b, node.Content[i+1])
+		}
+		b.WriteByte('}')
+	case yaml.SequenceNode:
+		b.WriteByte('[')
+		for i, item := range node.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeYAMLNodeJSON(b, item)
+		}
+		b.WriteByte(']')
+	case yaml.ScalarNode:
+		b.WriteString(yamlScalarJSON(node))
+	}
+}
+
+func yamlScalarJSON(node *yaml.Node) string {
+	switch node.ShortTag() {
+	case "!!null":
+		return "null"
+	case "!!bool":
+		var v bool
+		if node.Decode(&v) == nil {
+			return strconv.FormatBool(v)
+		}
+	case "!!int":
+		var v int64
+		if node.Decode(&v) == nil {
+			return strconv.FormatInt(v, 10)
+		}
+	case "!!float":
+		var v float64
+		if node.Decode(&v) == nil && !math.IsInf(v, 0) && !math.IsNaN(v) {
+			if json.Valid([]byte(node.Value)) {
+				return node.Value
+			}
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	}
+	return quoteJSON(node.Value)
+}
+
+// setYAMLValue stores value in node, leaving it untouched when the value
+// did not change. Comments attached to the node are kept.
+func setYAMLValue(node *yaml.Node, value string) {
+	if yamlValueString(node) == value {
+		return
+	}
+	repl := newYAMLValue(value, node)
+	repl.HeadComment = node.HeadComment
+	repl.LineComment = node.LineComment
+	repl.FootComment = node.FootComment
+	*node = *repl
+}
+
+// newYAMLValue builds the node for a value. JSON objects and lists become
+// block YAML; a scalar keeps the type of prev when the new value is still
+// valid for it, and is otherwise a string, quoted when it would read back
+// as null, a bool or a number.
+func newYAMLValue(value string, prev *yaml.Node) *yaml.Node {
+	trimmed := strings.TrimSpace(value)
+	if (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		var doc yaml.Node
+		if yaml.Unmarshal([]byte(trimmed), &doc) == nil && len(doc.Content) == 1 {
+			node := doc.Content[0]
+			clearYAMLStyle(node)
+			return node
+		}
+	}
+
+	node := &yaml.Node{Kind: yaml.ScalarNode, Value: value}
+	if prev != nil && prev.Kind == yaml.ScalarNode {
+		switch tag := prev.ShortTag(); tag {
+		case "!!int", "!!float", "!!bool":
+			if node.ShortTag() == tag {
+				node.Tag = tag
+				return node
+			}
+		}
+	}
+	if node.ShortTag() != "!!str" {
+		node.Tag = "!!str"
+	}
+	return node
+}
+
+// clearYAMLStyle switches a node parsed from JSON to block style with
+// plain scalars; the encoder quotes strings where needed.
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSaveEnvRoundTrip(t *testing.T) {
+	dir := writeEnvs(t, map[string]string{
+		"dev.yaml": "port: 8080\ndebug: false\nratio: 0.5\nname: api\n",
+	})
+	vars := models.EnvVariables{
+		"port":   "9090",
+		"debug":  "maybe",
+		"ratio":  "1.5",
+		"name":   "123",
+		"null":   "null",
+		"tilde":  "~",
+		"yes":    "true",
+		"number": "123",
+		"float":  "1e3",
+		"empty":  "",
+		"plain":  "hello",
+		"db":     `{"host":"localhost","port":5432,"flags":["true","null",1,false],"user":{"name":"~","admin":true}}`,
+		"list":   `["123",123,null,"null"]`,
+	}
+	if err := SaveEnv(dir, "dev", vars); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadEnv(dir, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, vars) {
+		t.Errorf("reloaded = %v, want %v", got, vars)
+	}
+
+	data, err := os.ReadFile(filepath.Join(GetEnvsDir(dir), "dev.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Existing scalars keep their type while the new value fits it; other
+	// values are strings, quoted where YAML would read them as another type
+	for _, line := range []string{
+		"port: 9090\n", "ratio: 1.5\n", "debug: maybe\n", `name: "123"`,
+		`null: "null"`, `tilde: "~"`, `yes: "true"`, `number: "123"`, `empty: ""`, "plain: hello\n",
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("saved file lacks %q:\n%s", line, data)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"carmelia-desktop/internal/models"
 	"io"
@@ -11,21 +13,40 @@ import (
 )
 
 type ExecuteOptions struct {
-	Method          string            `json:"method"`
-	URL             string            `json:"url"`
-	Headers         map[string]string `json:"headers"`
-	Body            string            `json:"body,omitempty"`
-	Timeout         int               `json:"timeout"`
-	FollowRedirects bool             `json:"followRedirects"`
+	Method          string         `json:"method"`
+	URL             string         `json:"url"`
+	Headers         models.Headers `json:"headers"`
+	Body            string         `json:"body,omitempty"`
+	Timeout         int            `json:"timeout"`
+	FollowRedirects bool           `json:"followRedirects"`
+	// Jar, when set, sends and stores cookies
+	Jar *CookieJar `json:"-"`
+	// TLS, when set, replaces the default TLS settings
+	TLS *tls.Config `json:"-"`
 }
 
-func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
+// managedHeaders are the headers net/http reads or replaces by their
+// canonical name, so they must be stored under it to take effect.
+var managedHeaders = map[string]bool{
+	"Accept-Encoding":   true,
+	"Authorization":     true,
+	"Connection":        true,
+	"Content-Length":    true,
+	"Content-Type":      true,
+	"Cookie":            true,
+	"Transfer-Encoding": true,
+	"User-Agent":        true,
+}
+
+// ExecuteRequest sends a request. Cancelling ctx aborts it, in flight
+// included.
+func ExecuteRequest(ctx context.Context, opts ExecuteOptions) (models.HttpResponse, error) {
 	timeout := time.Duration(opts.Timeout) * time.Millisecond
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	var bodyReader io.Reader
@@ -38,8 +59,20 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 		return models.HttpResponse{}, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	for key, value := range opts.Headers {
-		req.Header.Set(key, value)
+	// Repeated names are sent as repeated lines. Headers net/http manages
+	// are canonicalized so it sees them; the others keep the name case used
+	// in the file over HTTP/1.x (HTTP/2 lowercases every name). Host is not
+	// a header for net/http.
+	for _, h := range opts.Headers {
+		if strings.EqualFold(h.Name, "Host") {
+			req.Host = h.Value
+			continue
+		}
+		if managedHeaders[http.CanonicalHeaderKey(h.Name)] {
+			req.Header.Add(h.Name, h.Value)
+			continue
+		}
+		req.Header[h.Name] = append(req.Header[h.Name], h.Value)
 	}
 
 	// Default Content-Type to application/json when body is present
@@ -50,6 +83,12 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 	client := &http.Client{
 		Timeout: timeout,
 	}
+	if opts.Jar != nil {
+		client.Jar = opts.Jar
+	}
+	if opts.TLS != nil {
+		client.Transport = tlsTransport(opts.TLS)
+	}
 
 	if !opts.FollowRedirects {
 		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
@@ -62,7 +101,7 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 	elapsed := time.Since(start).Milliseconds()
 
 	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
 			return models.HttpResponse{}, fmt.Errorf("request timed out after %dms — %s %s", opts.Timeout, opts.Method, opts.URL)
 		}
 		if strings.Contains(err.Error(), "connection refused") {
@@ -77,24 +116,18 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 		return models.HttpResponse{}, fmt.Errorf("failed to read response body: %w", err)
 	}
 
-	responseHeaders := map[string]string{}
-	for key := range resp.Header {
-		responseHeaders[key] = resp.Header.Get(key)
+	// net/http does not keep the order of header names, so names are
+	// sorted; every value of a repeated header is kept in wire order.
+	responseHeaders := models.Headers{}
+	for _, key := range sortedKeys(resp.Header) {
+		for _, value := range resp.Header[key] {
+			responseHeaders.Add(key, value)
+		}
 	}
 
 	// Extract cookies
 	var cookies []models.CookieInfo
 	for _, c := range resp.Cookies() {
-		sameSite := ""
-		switch c.SameSite {
-		case http.SameSiteLaxMode:
-			sameSite = "Lax"
-		case http.SameSiteStrictMode:
-			sameSite = "Strict"
-		case http.SameSiteNoneMode:
-			sameSite = "None"
-		}
-
 		expires := ""
 		if !c.Expires.IsZero() {
 			expires = c.Expires.Format(time.RFC3339)
@@ -106,7 +139,7 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 			Domain:   c.Domain,
 			Path:     c.Path,
 			Expires:  expires,
-			SameSite: sameSite,
+			SameSite: sameSiteName(c.SameSite),
 			MaxAge:   c.MaxAge,
 			Secure:   c.Secure,
 			HttpOnly: c.HttpOnly,
@@ -121,5 +154,6 @@ func ExecuteRequest(opts ExecuteOptions) (models.HttpResponse, error) {
 		Time:       elapsed,
 		Size:       len(bodyBytes),
 		Cookies:    cookies,
+		TLS:        tlsInfo(resp.TLS),
 	}, nil
 }
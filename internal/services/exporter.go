@@ -24,6 +24,10 @@ func CollectAllRequests(projectPath string) ([]exportedRequest, error) {
 type exportedRequest struct {
 	Folder string
 	Name   string
+	// Path is the .http file relative to .carmelia/requests/ and Index the
+	// request inside it, as accepted by SelectRequest.
+	Path   string
+	Index  int
 	Parsed models.ParsedHttpRequest
 }
 
@@ -42,16 +46,28 @@ func collectFromTree(projectPath string, nodes []models.FileTreeNode, folder str
 			if err != nil {
 				continue
 			}
-			parsed := ParseHttpFile(content)
 			name := node.Name
 			if strings.HasSuffix(name, ".http") {
 				name = strings.TrimSuffix(name, ".http")
 			}
-			*out = append(*out, exportedRequest{
-				Folder: folder,
-				Name:   name,
-				Parsed: parsed,
-			})
+			requests := ParseHttpFileAll(content)
+			for _, parsed := range requests {
+				reqName := name
+				if len(requests) > 1 {
+					if parsed.Name != "" {
+						reqName = name + " - " + parsed.Name
+					} else {
+						reqName = fmt.Sprintf("%s #%d", name, parsed.Index+1)
+					}
+				}
+				*out = append(*out, exportedRequest{
+					Folder: folder,
+					Name:   reqName,
+					Path:   node.Path,
+					Index:  parsed.Index,
+					Parsed: parsed,
+				})
+			}
 		}
 	}
 }
@@ -100,8 +116,8 @@ func ExportPostmanV21(name string, requests []exportedRequest) (string, error) {
 
 func postmanItem(req exportedRequest) map[string]any {
 	headers := []map[string]string{}
-	for k, v := range req.Parsed.Headers {
-		headers = append(headers, map[string]string{"key": k, "value": v})
+	for _, h := range req.Parsed.Headers {
+		headers = append(headers, map[string]string{"key": h.Name, "value": h.Value})
 	}
 
 	item := map[string]any{
@@ -164,8 +180,8 @@ func ExportInsomniaV4(name string, requests []exportedRequest) (string, error) {
 		}
 
 		headers := []map[string]string{}
-		for k, v := range req.Parsed.Headers {
-			headers = append(headers, map[string]string{"name": k, "value": v})
+		for _, h := range req.Parsed.Headers {
+			headers = append(headers, map[string]string{"name": h.Name, "value": h.Value})
 		}
 
 		r := map[string]any{
@@ -179,8 +195,12 @@ func ExportInsomniaV4(name string, requests []exportedRequest) (string, error) {
 		}
 
 		if req.Parsed.Body != "" {
+			mimeType := req.Parsed.Headers.Get("Content-Type")
+			if mimeType == "" {
+				mimeType = "application/json"
+			}
 			r["body"] = map[string]any{
-				"mimeType": "application/json",
+				"mimeType": mimeType,
 				"text":     req.Parsed.Body,
 			}
 		}
@@ -261,7 +281,7 @@ func ExportOpenAPI30(name string, requests []exportedRequest) (string, error) {
 			}
 
 			if op.Request.Parsed.Body != "" {
-				contentType := op.Request.Parsed.Headers["Content-Type"]
+				contentType := op.Request.Parsed.Headers.Get("Content-Type")
 				if contentType == "" {
 					contentType = "application/json"
 				}
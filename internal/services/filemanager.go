@@ -231,29 +231,18 @@ func MoveItem(projectPath, srcRelPath, destParentRelPath string, destIndex int)
 	return newRelPath, nil
 }
 
+// detectMethod returns the method of the first request in a .http file.
 func detectMethod(filePath string) string {
 	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return ""
 	}
 
-	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
-			continue
-		}
-		parts := strings.Fields(trimmed)
-		if len(parts) >= 2 {
-			method := strings.ToUpper(parts[0])
-			switch method {
-			case "GET", "POST", "PUT", "DELETE", "PATCH", "HEAD", "OPTIONS":
-				return method
-			}
-		}
-		break
+	requests := ParseHttpFileAll(string(data))
+	if len(requests) == 0 {
+		return ""
 	}
-	return ""
+	return requests[0].Method
 }
 
 func ReadRequest(projectPath, relPath string) (string, error) {
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FilterFunc transforms a value in a `{{value | name args...}}` pipe.
+type FilterFunc func(value string, args []string) (string, error)
+
+var (
+	filtersMu sync.RWMutex
+	filters   = map[string]FilterFunc{
+		"base64":       noArgs(func(v string) string { return base64.StdEncoding.EncodeToString([]byte(v)) }),
+		"base64url":    noArgs(func(v string) string { return base64.RawURLEncoding.EncodeToString([]byte(v)) }),
+		"base64decode": base64Decode,
+		"hex":          noArgs(func(v string) string { return hex.EncodeToString([]byte(v)) }),
+		"urlencode":    noArgs(url.QueryEscape),
+		"urldecode":    func(v string, _ []string) (string, error) { return url.QueryUnescape(v) },
+		"jsonescape":   noArgs(func(v string) string { q := quoteJSON(v); return q[1 : len(q)-1] }),
+		"lower":        noArgs(strings.ToLower),
+		"upper":        noArgs(strings.ToUpper),
+		"trim":         noArgs(strings.TrimSpace),
+		"md5":          digestFilter(md5.New),
+		"sha1":         digestFilter(sha1.New),
+		"sha256":       digestFilter(sha256.New),
+		"sha512":       digestFilter(sha512.New),
+		"hmac_sha256":  hmacFilter(sha256.New),
+		"hmac_sha512":  hmacFilter(sha512.New),
+		"add":          arithmeticFilter(func(a, b float64) float64 { return a + b }),
+		"sub":          arithmeticFilter(func(a, b float64) float64 { return a - b }),
+		"mul":          arithmeticFilter(func(a, b float64) float64 { return a * b }),
+	}
+)
+
+// RegisterFilter makes fn available as `{{... | name}}`, replacing any
+// filter already registered under that name.
+func RegisterFilter(name string, fn FilterFunc) {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	filters[name] = fn
+}
+
+func lookupFilter(name string) (FilterFunc, bool) {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+	fn, ok := filters[name]
+	return fn, ok
+}
+
+func noArgs(fn func(string) string) FilterFunc {
+	return func(v string, _ []string) (string, error) {
+		return fn(v), nil
+	}
+}
+
+func base64Decode(v string, _ []string) (string, error) {
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if out, err := enc.DecodeString(v); err == nil {
+			return string(out), nil
+		}
+	}
+	return "", fmt.Errorf("base64decode: invalid input")
+}
+
+// digestFilter returns the raw digest; pipe it to hex or base64.
+func digestFilter(newHash func() hash.Hash) FilterFunc {
+	return func(v string, _ []string) (string, error) {
+		h := newHash()
+		h.Write([]byte(v))
+		return string(h.Sum(nil)), nil
+	}
+}
+
+// hmacFilter returns the raw MAC of the value keyed with the first argument.
+func hmacFilter(newHash func() hash.Hash) FilterFunc {
+	return func(v string, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("expects a key argument")
+		}
+		mac := hmac.New(newHash, []byte(args[0]))
+		mac.Write([]byte(v))
+		return string(mac.Sum(nil)), nil
+	}
+}
+
+// arithmeticFilter combines the value with a numeric argument. Integers stay
+// integers; anything else is formatted as the shortest float.
+func arithmeticFilter(op func(a, b float64) float64) FilterFunc {
+	return func(v string, args []string) (string, error) {
+		if len(args) != 1 {
+			return "", fmt.Errorf("expects one number")
+		}
+		a, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return "", fmt.Errorf("value %q is not a number", v)
+		}
+		b, err := strconv.ParseFloat(args[0], 64)
+		if err != nil {
+			return "", fmt.Errorf("argument %q is not a number", args[0])
+		}
+		out := op(a, b)
+		if out == float64(int64(out)) {
+			return strconv.FormatInt(int64(out), 10), nil
+		}
+		return strconv.FormatFloat(out, 'f', -1, 64), nil
+	}
+}
+
+// filterArg is an argument of a filter; quoted arguments are always literal.
+type filterArg struct {
+	text   string
+	quoted bool
+}
+
+// splitPipes splits `head | f1 a | f2` on pipes outside quotes.
+func splitPipes(expr string) []string {
+	var parts []string
+	quote := byte(0)
+	start := 0
+	for i := 0; i < len(expr); i++ {
+		ch := expr[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '|':
+			parts = append(parts, strings.TrimSpace(expr[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(expr[start:]))
+}
+
+// splitFilterArgs splits a filter call into its name and arguments.
+func splitFilterArgs(call string) (string, []filterArg) {
+	var args []filterArg
+	var cur strings.Builder
+	quote := byte(0)
+	quoted, inArg := false, false
+	flush := func() {
+		if inArg {
+			args = append(args, filterArg{text: cur.String(), quoted: quoted})
+		}
+		cur.Reset()
+		quoted, inArg = false, false
+	}
+	for i := 0; i < len(call); i++ {
+		ch := call[i]
+		switch {
+		case quote != 0 && ch == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteByte(ch)
+		case ch == '"' || ch == '\'':
+			quote, quoted, inArg = ch, true, true
+		case ch == ' ' || ch == '\t':
+			flush()
+		default:
+			cur.WriteByte(ch)
+			inArg = true
+		}
+	}
+	flush()
+	if len(args) == 0 {
+		return "", nil
+	}
+	return args[0].text, args[1:]
+}
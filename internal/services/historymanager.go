@@ -16,6 +16,23 @@ func historyDir(projectPath, requestPath string) string {
 	return filepath.Join(projectPath, ".carmelia", "history", hash)
 }
 
+// RequestHistoryKey returns the history key of one request inside a file:
+// the file key followed by the request's @name, or by its index when it has
+// none, so history follows a named request when others are added or
+// reordered around it. Single-request files keep the plain key so existing
+// history still applies.
+func RequestHistoryKey(fileKey string, req models.ParsedHttpRequest, total int) string {
+	if total <= 1 {
+		return fileKey
+	}
+	if req.Name != "" {
+		return fileKey + "#" + req.Name
+	}
+	return fmt.Sprintf("%s#%d", fileKey, req.Index)
+}
+
+// SaveHistoryEntry stores result as given: secrets must be masked already,
+// as runRequest does with redactRunResult.
 func SaveHistoryEntry(projectPath, requestPath string, maxEntries int, result models.RunResult) error {
 	dir := historyDir(projectPath, requestPath)
 	if err := os.MkdirAll(dir, 0o755); err != nil {
@@ -23,21 +40,38 @@ func SaveHistoryEntry(projectPath, requestPath string, maxEntries int, result mo
 	}
 
 	entry := models.HistoryEntry{
-		ID:        fmt.Sprintf("%d", time.Now().UnixMilli()),
-		Timestamp: time.Now().UnixMilli(),
-		Request:   result.Request,
-		Response:  result.Response,
-		Error:     result.Error,
+		Timestamp:  time.Now().UnixMilli(),
+		Request:    result.Request,
+		Response:   result.Response,
+		Error:      result.Error,
+		Assertions: result.Assertions,
 	}
 
-	data, err := json.MarshalIndent(entry, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal history entry: %w", err)
-	}
-
-	filename := fmt.Sprintf("%d.json", entry.Timestamp)
-	if err := os.WriteFile(filepath.Join(dir, filename), data, 0o644); err != nil {
-		return fmt.Errorf("failed to write history entry: %w", err)
+	// Entries saved in the same millisecond, such as the iterations of a
+	// data file run, get a suffix that sorts after the first one
+	for n := 0; ; n++ {
+		entry.ID = fmt.Sprintf("%d", entry.Timestamp)
+		if n > 0 {
+			entry.ID += fmt.Sprintf("_%03d", n)
+		}
+		data, err := json.MarshalIndent(entry, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal history entry: %w", err)
+		}
+		f, err := os.OpenFile(filepath.Join(dir, entry.ID+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err == nil {
+			_, err = f.Write(data)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write history entry: %w", err)
+		}
+		break
 	}
 
 	// Enforce max entries
@@ -80,7 +114,10 @@ func LoadHistory(projectPath, requestPath string) ([]models.HistoryEntry, error)
 
 	// Sort by timestamp descending (newest first)
 	sort.Slice(history, func(i, j int) bool {
-		return history[i].Timestamp > history[j].Timestamp
+		if history[i].Timestamp != history[j].Timestamp {
+			return history[i].Timestamp > history[j].Timestamp
+		}
+		return history[i].ID > history[j].ID
 	})
 
 	return history, nil
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"fmt"
+	"testing"
+)
+
+func TestRequestHistoryKey(t *testing.T) {
+	requests := ParseHttpFileAll("### login\nPOST /login\n###\nGET /me\n### Get user\n# @name user\nGET /users/1\n")
+	tests := []struct {
+		index int
+		total int
+		want  string
+	}{
+		{0, 1, "users.http"},
+		{0, 3, "users.http#login"},
+		{1, 3, "users.http#1"},
+		{2, 3, "users.http#user"},
+	}
+	for _, tt := range tests {
+		if got := RequestHistoryKey("users.http", requests[tt.index], tt.total); got != tt.want {
+			t.Errorf("RequestHistoryKey(request %d of %d) = %q, want %q", tt.index, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestSaveHistoryEntrySameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 25; i++ {
+		result := models.RunResult{Request: models.ParsedHttpRequest{Method: "GET", URL: fmt.Sprintf("/%d", i)}}
+		if err := SaveHistoryEntry(dir, "users.http", 20, result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history, err := LoadHistory(dir, "users.http")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 20 {
+		t.Fatalf("got %d entries, want 20", len(history))
+	}
+	ids := map[string]bool{}
+	for i, entry := range history {
+		if want := fmt.Sprintf("/%d", 24-i); entry.Request.URL != want {
+			t.Errorf("entry %d is %s, want %s", i, entry.Request.URL, want)
+		}
+		if ids[entry.ID] {
+			t.Errorf("duplicate ID %s", entry.ID)
+		}
+		ids[entry.ID] = true
+	}
+}
@@ -2,26 +2,193 @@ package services
 
 import (
 	"carmelia-desktop/internal/models"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var methodRegex = regexp.MustCompile(`(?i)^(GET|POST|PUT|DELETE|PATCH|HEAD|OPTIONS)\s+(.+)$`)
 var headerRegex = regexp.MustCompile(`^([\w-]+)\s*:\s*(.+)$`)
+var fileVarRegex = regexp.MustCompile(`^@(\w+)\s*=\s*(.*)$`)
+var scriptRegex = regexp.MustCompile(`^@(pre|post)\s+(.+)$`)
+var captureRegex = regexp.MustCompile(`^@capture\s+(?:(env|runtime)\s+)?([\w.-]+)\s*=\s*(.+)$`)
 
+// Delimiters of an inline script block.
+const (
+	scriptOpen  = "{%"
+	scriptClose = "%}"
+)
+
+// requestSeparator starts a new request block in a .http file (JetBrains /
+// VS Code REST Client style). Any text after it, separated by whitespace,
+// names the request.
+const requestSeparator = "###"
+
+// ParseHttpFile parses the first request of a .http file. Files without any
+// request line still yield their comments and docs.
 func ParseHttpFile(content string) models.ParsedHttpRequest {
+	requests := ParseHttpFileAll(content)
+	if len(requests) > 0 {
+		return requests[0]
+	}
+	blocks := splitRequestBlocks(content)
+	req := parseRequestBlock(blocks[0].lines, blocks[0].name, blocks[0].startLine)
+	req.FileVariables = collectFileVariables(blocks)
+	return req
+}
+
+// ParseHttpFileAll parses every request of a .http file split by `###`
+// separators. Blocks without a request line are skipped; Index is the
+// position in the returned list and StartLine/EndLine are 1-based.
+// `@name = value` declarations anywhere in the file are attached to every
+// request as FileVariables.
+func ParseHttpFileAll(content string) []models.ParsedHttpRequest {
+	blocks := splitRequestBlocks(content)
+	fileVars := collectFileVariables(blocks)
+
+	requests := []models.ParsedHttpRequest{}
+	for _, block := range blocks {
+		req := parseRequestBlock(block.lines, block.name, block.startLine)
+		if req.Method == "" {
+			continue
+		}
+		req.Index = len(requests)
+		req.FileVariables = fileVars
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// SelectRequest picks one request by target: empty selects the first, a
+// number selects by index, anything else matches the request name.
+func SelectRequest(requests []models.ParsedHttpRequest, target string) (models.ParsedHttpRequest, error) {
+	if len(requests) == 0 {
+		return models.ParsedHttpRequest{}, fmt.Errorf("no request found")
+	}
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return requests[0], nil
+	}
+	if idx, err := strconv.Atoi(target); err == nil {
+		if idx < 0 || idx >= len(requests) {
+			return models.ParsedHttpRequest{}, fmt.Errorf("request index %d out of range (%d requests)", idx, len(requests))
+		}
+		return requests[idx], nil
+	}
+	for _, req := range requests {
+		if req.Name == target {
+			return req, nil
+		}
+	}
+	return models.ParsedHttpRequest{}, fmt.Errorf("request %q not found", target)
+}
+
+// collectFileVariables returns the `@name = value` declarations found before
+// the request line of each block, in file order.
+func collectFileVariables(blocks []requestBlock) []models.FileVariable {
+	var vars []models.FileVariable
+	for _, block := range blocks {
+		for _, line := range block.lines {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			match := fileVarRegex.FindStringSubmatch(trimmed)
+			if match == nil {
+				if methodRegex.MatchString(trimmed) {
+					break
+				}
+				continue
+			}
+			vars = append(vars, models.FileVariable{
+				Name:  match[1],
+				Value: strings.TrimSpace(match[2]),
+			})
+		}
+	}
+	return vars
+}
+
+type requestBlock struct {
+	name      string
+	startLine int
+	lines     []string
+}
+
+// splitRequestBlocks splits content on `###` separator lines. It always
+// returns at least one block. As in JetBrains and VS Code, a separator line
+// also ends a body, so a body cannot contain a line that is only `###`;
+// `####` headings and lines such as `###foo` are kept.
+func splitRequestBlocks(content string) []requestBlock {
 	lines := strings.Split(content, "\n")
+	blocks := []requestBlock{{startLine: 1}}
+	for i, line := range lines {
+		if name, ok := separatorName(line); ok {
+			blocks = append(blocks, requestBlock{
+				name:      name,
+				startLine: i + 2,
+			})
+			continue
+		}
+		cur := &blocks[len(blocks)-1]
+		cur.lines = append(cur.lines, line)
+	}
+	return blocks
+}
+
+// separatorName reports whether line is a `###` separator, alone or
+// followed by whitespace and the request name.
+func separatorName(line string) (string, bool) {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(line), requestSeparator)
+	if !ok {
+		return "", false
+	}
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+func parseRequestBlock(lines []string, name string, startLine int) models.ParsedHttpRequest {
 	comments := []string{}
 	method := ""
 	url := ""
-	headers := map[string]string{}
+	headers := models.Headers{}
 	bodyLines := []string{}
 	phase := "comments" // comments | request-line | headers | body
 
 	docs := models.RequestDocs{}
+	var captures []models.Capture
+	var assertions []string
+	var scripts []models.Script
+	var script *models.Script // open `{% ... %}` block
+	noCookieJar := false
+	firstLine := 0
+	lastLine := 0
 
-	for _, line := range lines {
+	for i, line := range lines {
 		trimmed := strings.TrimSpace(line)
+		if firstLine == 0 && trimmed != "" {
+			firstLine = startLine + i
+		}
+
+		if phase == "comments" && script != nil {
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				code := strings.TrimPrefix(strings.TrimPrefix(trimmed, "#"), " ")
+				if before, _, closed := strings.Cut(code, scriptClose); closed {
+					script.Code += before
+					scripts = append(scripts, *script)
+					script = nil
+				} else {
+					script.Code += code + "\n"
+				}
+				continue
+			}
+			// Unterminated block: keep what was read
+			scripts = append(scripts, *script)
+			script = nil
+		}
 
 		if phase == "comments" {
 			if trimmed == "" {
@@ -30,6 +197,66 @@ func ParseHttpFile(content string) models.ParsedHttpRequest {
 			if strings.HasPrefix(trimmed, "#") {
 				commentText := strings.TrimSpace(trimmed[1:])
 
+				// Scripts run around the request: an inline `{% ... %}`
+				// block, possibly spanning comment lines, or a .js file
+				if match := scriptRegex.FindStringSubmatch(commentText); match != nil {
+					s := models.Script{Phase: match[1]}
+					rest := strings.TrimSpace(match[2])
+					if code, ok := strings.CutPrefix(rest, scriptOpen); ok {
+						if before, _, closed := strings.Cut(code, scriptClose); closed {
+							s.Code = strings.TrimSpace(before)
+							scripts = append(scripts, s)
+						} else {
+							s.Code = strings.TrimSpace(code)
+							if s.Code != "" {
+								s.Code += "\n"
+							}
+							script = &s
+						}
+					} else {
+						s.File = rest
+						scripts = append(scripts, s)
+					}
+					comments = append(comments, commentText)
+					continue
+				}
+
+				// Named request, referenced as {{name.response...}}
+				if strings.HasPrefix(commentText, "@name ") {
+					name = strings.TrimSpace(commentText[6:])
+					comments = append(comments, commentText)
+					continue
+				}
+
+				// Response captures, run after the request succeeds
+				if match := captureRegex.FindStringSubmatch(commentText); match != nil {
+					scope := match[1]
+					if scope == "" {
+						scope = CaptureRuntime
+					}
+					captures = append(captures, models.Capture{
+						Name:  match[2],
+						Expr:  strings.TrimSpace(match[3]),
+						Scope: scope,
+					})
+					comments = append(comments, commentText)
+					continue
+				}
+
+				// Opt out of the env's cookie jar
+				if commentText == "@no-cookie-jar" {
+					noCookieJar = true
+					comments = append(comments, commentText)
+					continue
+				}
+
+				// Assertions, checked against the response
+				if strings.HasPrefix(commentText, "@assert ") {
+					assertions = append(assertions, strings.TrimSpace(commentText[8:]))
+					comments = append(comments, commentText)
+					continue
+				}
+
 				// Parse doc annotations
 				if strings.HasPrefix(commentText, "@summary ") {
 					docs.Summary = strings.TrimSpace(commentText[9:])
@@ -63,6 +290,10 @@ func ParseHttpFile(content string) models.ParsedHttpRequest {
 				comments = append(comments, commentText)
 				continue
 			}
+			if fileVarRegex.MatchString(trimmed) {
+				// File variables are collected for the whole file
+				continue
+			}
 			phase = "request-line"
 		}
 
@@ -71,6 +302,7 @@ func ParseHttpFile(content string) models.ParsedHttpRequest {
 			if match != nil {
 				method = strings.ToUpper(match[1])
 				url = strings.TrimSpace(match[2])
+				lastLine = startLine + i
 				phase = "headers"
 				continue
 			}
@@ -85,21 +317,30 @@ func ParseHttpFile(content string) models.ParsedHttpRequest {
 
 			headerMatch := headerRegex.FindStringSubmatch(trimmed)
 			if headerMatch != nil {
-				headers[headerMatch[1]] = strings.TrimSpace(headerMatch[2])
+				headers.Add(headerMatch[1], strings.TrimSpace(headerMatch[2]))
+				lastLine = startLine + i
 				continue
 			}
 
 			// Not a header — start of body
 			phase = "body"
 			bodyLines = append(bodyLines, line)
+			lastLine = startLine + i
 			continue
 		}
 
 		if phase == "body" {
 			bodyLines = append(bodyLines, line)
+			if trimmed != "" {
+				lastLine = startLine + i
+			}
 		}
 	}
 
+	if script != nil {
+		scripts = append(scripts, *script)
+	}
+
 	// Trim trailing empty lines from body
 	for len(bodyLines) > 0 && strings.TrimSpace(bodyLines[len(bodyLines)-1]) == "" {
 		bodyLines = bodyLines[:len(bodyLines)-1]
@@ -111,11 +352,18 @@ func ParseHttpFile(content string) models.ParsedHttpRequest {
 	}
 
 	return models.ParsedHttpRequest{
-		Method:   method,
-		URL:      url,
-		Headers:  headers,
-		Body:     body,
-		Comments: comments,
-		Docs:     docs,
+		Name:        name,
+		StartLine:   firstLine,
+		EndLine:     lastLine,
+		Method:      method,
+		URL:         url,
+		Headers:     headers,
+		Body:        body,
+		Comments:    comments,
+		Docs:        docs,
+		Captures:    captures,
+		Assertions:  assertions,
+		Scripts:     scripts,
+		NoCookieJar: noCookieJar,
 	}
 }
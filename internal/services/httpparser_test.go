@@ -0,0 +1,205 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestSeparatorName(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		ok   bool
+	}{
+		{"###", "", true},
+		{"  ###  ", "", true},
+		{"### Get user", "Get user", true},
+		{"###\tLogin", "Login", true},
+		{"####", "", false},
+		{"#### Heading", "", false},
+		{"###foo", "", false},
+		{"# ### not a separator", "", false},
+		{"GET /###", "", false},
+	}
+	for _, tt := range tests {
+		name, ok := separatorName(tt.line)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("separatorName(%q) = %q, %v; want %q, %v", tt.line, name, ok, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestParseHttpFileAll(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string // method and URL of each request
+		names   []string
+	}{
+		{
+			name:    "single request",
+			content: "GET https://example.com/users",
+			want:    []string{"GET https://example.com/users"},
+			names:   []string{""},
+		},
+		{
+			name:    "named blocks",
+			content: "### first\nGET /a\n\n### second\npost /b\n",
+			want:    []string{"GET /a", "POST /b"},
+			names:   []string{"first", "second"},
+		},
+		{
+			name:    "blocks without a request line are skipped",
+			content: "# just a comment\n###\nGET /a\n###\n# nothing here\n",
+			want:    []string{"GET /a"},
+			names:   []string{""},
+		},
+		{
+			name:    "@name overrides the separator name",
+			content: "### block\n# @name login\nPOST /login\n",
+			want:    []string{"POST /login"},
+			names:   []string{"login"},
+		},
+		{
+			name:    "#### lines stay in the body",
+			content: "POST /notes\nContent-Type: text/markdown\n\n#### Heading\ntext\n### next\nGET /b",
+			want:    []string{"POST /notes", "GET /b"},
+			names:   []string{"", "next"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := ParseHttpFileAll(tt.content)
+			if len(requests) != len(tt.want) {
+				t.Fatalf("got %d requests, want %d", len(requests), len(tt.want))
+			}
+			for i, req := range requests {
+				if got := req.Method + " " + req.URL; got != tt.want[i] {
+					t.Errorf("request %d = %q, want %q", i, got, tt.want[i])
+				}
+				if req.Name != tt.names[i] {
+					t.Errorf("request %d name = %q, want %q", i, req.Name, tt.names[i])
+				}
+				if req.Index != i {
+					t.Errorf("request %d index = %d", i, req.Index)
+				}
+			}
+		})
+	}
+}
+
+func TestParseHttpFileBody(t *testing.T) {
+	tests := []struct {
+		content    string
+		body       string
+		start, end int
+	}{
+		{"POST /notes\nContent-Type: text/plain\n\nline 1\n\nline 2\n\n\n### next\nGET /b", "line 1\n\nline 2", 1, 6},
+		{"\n# comment\nPUT /a\n\n{}\n", "{}", 2, 5},
+		{"POST /notes\nContent-Type: text/markdown\n\n#### Heading\n###text\n\n\n### next\nGET /b", "#### Heading\n###text", 1, 5},
+	}
+	for _, tt := range tests {
+		req := ParseHttpFile(tt.content)
+		if req.Body != tt.body {
+			t.Errorf("body = %q, want %q", req.Body, tt.body)
+		}
+		if req.StartLine != tt.start || req.EndLine != tt.end {
+			t.Errorf("lines = %d-%d, want %d-%d", req.StartLine, req.EndLine, tt.start, tt.end)
+		}
+	}
+}
+
+func TestParseHttpFileHeaders(t *testing.T) {
+	content := "GET /a\nAccept: text/html\naccept: application/json\nX-Trace-Id: {{id}}\n"
+	want := models.Headers{
+		{Name: "Accept", Value: "text/html"},
+		{Name: "accept", Value: "application/json"},
+		{Name: "X-Trace-Id", Value: "{{id}}"},
+	}
+	if got := ParseHttpFile(content).Headers; !reflect.DeepEqual(got, want) {
+		t.Errorf("headers = %v, want %v", got, want)
+	}
+}
+
+func TestParseHttpFileDirectives(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		got     func(models.ParsedHttpRequest) any
+		want    any
+	}{
+		{
+			name:    "file variables",
+			content: "@host = https://example.com\n@token={{login.response.body.$.token}}\n### login\nPOST {{host}}/login\n",
+			got:     func(r models.ParsedHttpRequest) any { return r.FileVariables },
+			want: []models.FileVariable{
+				{Name: "host", Value: "https://example.com"},
+				{Name: "token", Value: "{{login.response.body.$.token}}"},
+			},
+		},
+		{
+			name:    "captures",
+			content: "# @capture token = $.token\n# @capture env user = header X-User\nPOST /login\n",
+			got:     func(r models.ParsedHttpRequest) any { return r.Captures },
+			want: []models.Capture{
+				{Name: "token", Expr: "$.token", Scope: CaptureRuntime},
+				{Name: "user", Expr: "header X-User", Scope: "env"},
+			},
+		},
+		{
+			name:    "assertions",
+			content: "# @assert status == 200\n# @assert $.id exists\nGET /a\n",
+			got:     func(r models.ParsedHttpRequest) any { return r.Assertions },
+			want:    []string{"status == 200", "$.id exists"},
+		},
+		{
+			name:    "scripts",
+			content: "# @pre {% request.headers.set(\"X-A\", \"1\") %}\n# @post ./check.js\nGET /a\n",
+			got:     func(r models.ParsedHttpRequest) any { return r.Scripts },
+			want: []models.Script{
+				{Phase: "pre", Code: `request.headers.set("X-A", "1")`},
+				{Phase: "post", File: "./check.js"},
+			},
+		},
+		{
+			name:    "cookie jar opt-out",
+			content: "# @no-cookie-jar\nGET /a\n",
+			got:     func(r models.ParsedHttpRequest) any { return r.NoCookieJar },
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.got(ParseHttpFile(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectRequest(t *testing.T) {
+	requests := ParseHttpFileAll("### a\nGET /a\n### b\nGET /b\n")
+	tests := []struct {
+		target  string
+		want    string
+		wantErr bool
+	}{
+		{"", "/a", false},
+		{"1", "/b", false},
+		{"b", "/b", false},
+		{"2", "", true},
+		{"-1", "", true},
+		{"missing", "", true},
+	}
+	for _, tt := range tests {
+		req, err := SelectRequest(requests, tt.target)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SelectRequest(%q) error = %v, wantErr %v", tt.target, err, tt.wantErr)
+			continue
+		}
+		if req.URL != tt.want {
+			t.Errorf("SelectRequest(%q) = %q, want %q", tt.target, req.URL, tt.want)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// importedRequest is a request read from another tool's collection. Folder
+// uses "/" between levels.
+type importedRequest struct {
+	Folder  string
+	Name    string
+	Method  string
+	URL     string
+	Headers models.Headers
+	Body    string
+}
+
+// DetectImportFormat tells a Postman collection ("postman") from an
+// Insomnia export ("insomnia"). Anything else is taken as a curl command.
+func DetectImportFormat(data []byte) string {
+	var probe struct {
+		Info *json.RawMessage `json:"info"`
+		Item *json.RawMessage `json:"item"`
+		Type string           `json:"_type"`
+	}
+	if json.Unmarshal(data, &probe) == nil {
+		switch {
+		case probe.Info != nil && probe.Item != nil:
+			return "postman"
+		case probe.Type == "export":
+			return "insomnia"
+		}
+	}
+	return "curl"
+}
+
+// ImportCollection writes one .http file per request of a Postman v2.1
+// collection, an Insomnia v4 export or a curl command under parentDir
+// (relative to .carmelia/requests/), keeping the collection's folders.
+// An empty format is detected from data. Existing files are never
+// overwritten, and the new files and folders are added to order.json in
+// the collection's order. It returns the created paths, relative to
+// .carmelia/requests/.
+func ImportCollection(projectPath, parentDir string, data []byte, format string) ([]string, error) {
+	if format == "" {
+		format = DetectImportFormat(data)
+	}
+
+	var requests []importedRequest
+	var err error
+	switch format {
+	case "postman":
+		requests, err = parsePostmanCollection(data)
+	case "insomnia":
+		requests, err = parseInsomniaExport(data)
+	case "curl":
+		var content string
+		if content, err = ParseCurl(string(data)); err == nil {
+			parsed := ParseHttpFile(content)
+			requests = []importedRequest{{
+				Name:    curlRequestName(parsed),
+				Method:  parsed.Method,
+				URL:     parsed.URL,
+				Headers: parsed.Headers,
+				Body:    parsed.Body,
+			}}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no requests found to import")
+	}
+
+	requestsDir := filepath.Join(projectPath, ".carmelia", "requests")
+	var created []string
+	added := map[string][]string{} // directory → new items, in order
+	addItem := func(path string) {
+		parent, _ := filepath.Rel(requestsDir, filepath.Dir(path))
+		if parent == "." {
+			parent = ""
+		}
+		added[parent] = append(added[parent], filepath.Base(path))
+	}
+	for _, req := range requests {
+		dir := requestsDir
+		var newDirs []string
+		parts := strings.Split(filepath.ToSlash(parentDir), "/")
+		for i, part := range append(parts, strings.Split(req.Folder, "/")...) {
+			if part == "" {
+				continue
+			}
+			if i >= len(parts) {
+				part = importFileName(part)
+			}
+			dir = filepath.Join(dir, part)
+			if _, statErr := os.Stat(dir); os.IsNotExist(statErr) {
+				newDirs = append(newDirs, dir)
+			}
+		}
+		if err = os.MkdirAll(dir, 0o755); err != nil {
+			err = fmt.Errorf("failed to create directory: %w", err)
+			break
+		}
+		for _, newDir := range newDirs {
+			addItem(newDir)
+		}
+
+		fullPath := uniqueRequestPath(dir, importFileName(req.Name))
+		if err = os.WriteFile(fullPath, []byte(formatImportedRequest(req)), 0o644); err != nil {
+			err = fmt.Errorf("failed to write request file: %w", err)
+			break
+		}
+		addItem(fullPath)
+		relPath, _ := filepath.Rel(requestsDir, fullPath)
+		created = append(created, relPath)
+	}
+
+	if orderErr := appendOrder(projectPath, added); orderErr != nil && err == nil {
+		err = fmt.Errorf("failed to save order: %w", orderErr)
+	}
+	return created, err
+}
+
+func parsePostmanCollection(data []byte) ([]importedRequest, error) {
+	var collection struct {
+		Item []postmanImportItem `json:"item"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil {
+		return nil, fmt.Errorf("invalid Postman collection: %w", err)
+	}
+
+	var requests []importedRequest
+	var walk func(items []postmanImportItem, folder string)
+	walk = func(items []postmanImportItem, folder string) {
+		for _, item := range items {
+			if item.Item != nil {
+				walk(*item.Item, joinImportFolder(folder, item.Name))
+				continue
+			}
+			if item.Request != nil {
+				requests = append(requests, item.Request.toImported(folder, item.Name))
+			}
+		}
+	}
+	walk(collection.Item, "")
+	return requests, nil
+}
+
+type postmanImportItem struct {
+	Name    string               `json:"name"`
+	Item    *[]postmanImportItem `json:"item"`
+	Request *postmanImportReq    `json:"request"`
+}
+
+type postmanKeyValue struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Disabled bool   `json:"disabled"`
+}
+
+// postmanImportReq accepts both the object form of a request and the
+// shorthand where the request (or its url) is a plain string.
+type postmanImportReq struct {
+	Method string
+	URL    string
+	Header []postmanKeyValue
+	Body   struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw"`
+		URLEncoded []postmanKeyValue `json:"urlencoded"`
+	}
+}
+
+func (r *postmanImportReq) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.URL); err == nil {
+		r.Method = "GET"
+		return nil
+	}
+
+	var raw struct {
+		Method string            `json:"method"`
+		URL    json.RawMessage   `json:"url"`
+		Header []postmanKeyValue `json:"header"`
+		Body   json.RawMessage   `json:"body"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	r.Method = raw.Method
+	r.Header = raw.Header
+	if len(raw.Body) > 0 {
+		if err := json.Unmarshal(raw.Body, &r.Body); err != nil {
+			return err
+		}
+	}
+	if len(raw.URL) > 0 {
+		if err := json.Unmarshal(raw.URL, &r.URL); err != nil {
+			var u struct {
+				Raw string `json:"raw"`
+			}
+			if err := json.Unmarshal(raw.URL, &u); err != nil {
+				return err
+			}
+			r.URL = u.Raw
+		}
+	}
+	return nil
+}
+
+func (r postmanImportReq) toImported(folder, name string) importedRequest {
+	req := importedRequest{
+		Folder: folder,
+		Name:   name,
+		Method: strings.ToUpper(r.Method),
+		URL:    r.URL,
+	}
+	if req.Method == "" {
+		req.Method = "GET"
+	}
+	for _, h := range r.Header {
+		if !h.Disabled {
+			req.Headers.Add(h.Key, h.Value)
+		}
+	}
+
+	switch r.Body.Mode {
+	case "raw":
+		req.Body = r.Body.Raw
+	case "urlencoded":
+		var pairs []string
+		for _, p := range r.Body.URLEncoded {
+			if !p.Disabled {
+				pairs = append(pairs, p.Key+"="+p.Value)
+			}
+		}
+		req.Body = strings.Join(pairs, "&")
+		if req.Body != "" && !req.Headers.Has("Content-Type") {
+			req.Headers.Add("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	return req
+}
+
+// insomniaVarRegex matches Insomnia's {{ _.name }} references.
+var insomniaVarRegex = regexp.MustCompile(`\{\{\s*_\.([\w.-]+)\s*\}\}`)
+
+func parseInsomniaExport(data []byte) ([]importedRequest, error) {
+	var export struct {
+		Resources []struct {
+			ID       string `json:"_id"`
+			Type     string `json:"_type"`
+			ParentID string `json:"parentId"`
+			Name     string `json:"name"`
+			Method   string `json:"method"`
+			URL      string `json:"url"`
+			Headers  []struct {
+				Name     string `json:"name"`
+				Value    string `json:"value"`
+				Disabled bool   `json:"disabled"`
+			} `json:"headers"`
+			Body struct {
+				MimeType string `json:"mimeType"`
+				Text     string `json:"text"`
+			} `json:"body"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid Insomnia export: %w", err)
+	}
+
+	groups := map[string]struct{ name, parent string }{}
+	for _, res := range export.Resources {
+		if res.Type == "request_group" {
+			groups[res.ID] = struct{ name, parent string }{res.Name, res.ParentID}
+		}
+	}
+	folderOf := func(parentID string) string {
+		var parts []string
+		for seen := 0; seen <= len(groups); seen++ {
+			group, ok := groups[parentID]
+			if !ok {
+				break
+			}
+			parts = append([]string{group.name}, parts...)
+			parentID = group.parent
+		}
+		return strings.Join(parts, "/")
+	}
+	convert := func(s string) string {
+		return insomniaVarRegex.ReplaceAllString(s, "{{$1}}")
+	}
+
+	var requests []importedRequest
+	for _, res := range export.Resources {
+		if res.Type != "request" {
+			continue
+		}
+		req := importedRequest{
+			Folder: folderOf(res.ParentID),
+			Name:   res.Name,
+			Method: strings.ToUpper(res.Method),
+			URL:    convert(res.URL),
+			Body:   convert(res.Body.Text),
+		}
+		if req.Method == "" {
+			req.Method = "GET"
+		}
+		for _, h := range res.Headers {
+			if !h.Disabled {
+				req.Headers.Add(h.Name, convert(h.Value))
+			}
+		}
+		if req.Body != "" && res.Body.MimeType != "" && !req.Headers.Has("Content-Type") {
+			req.Headers.Add("Content-Type", res.Body.MimeType)
+		}
+		requests = append(requests, req)
+	}
+	return requests, nil
+}
+
+func joinImportFolder(folder, name string) string {
+	if folder == "" {
+		return name
+	}
+	return folder + "/" + name
+}
+
+// importFileNameRegex matches characters that are not safe in file names.
+var importFileNameRegex = regexp.MustCompile(`[\\/:*?"<>|\x00-\x1f]+`)
+
+// importFileName turns a request or folder name into a file name.
+func importFileName(name string) string {
+	name = importFileNameRegex.ReplaceAllString(name, "-")
+	name = strings.Trim(name, " .-")
+	if name == "" {
+		return "request"
+	}
+	return name
+}
+
+// curlRequestName names an imported curl request after its URL, without
+// the scheme or query.
+func curlRequestName(parsed models.ParsedHttpRequest) string {
+	name := parsed.URL
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	return parsed.Method + " " + name
+}
+
+// uniqueRequestPath returns dir/name.http, numbering the name when the
+// file already exists.
+func uniqueRequestPath(dir, name string) string {
+	path := filepath.Join(dir, name+".http")
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s %d.http", name, i))
+	}
+}
+
+// formatImportedRequest writes a request as .http file content.
+func formatImportedRequest(req importedRequest) string {
+	var b strings.Builder
+	b.WriteString(req.Method + " " + req.URL + "\n")
+	for _, h := range req.Headers {
+		b.WriteString(h.Name + ": " + h.Value + "\n")
+	}
+	if req.Body != "" {
+		b.WriteString("\n" + req.Body)
+		if !strings.HasSuffix(req.Body, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
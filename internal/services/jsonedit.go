@@ -0,0 +1,392 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// jsonNode is a JSON value together with its byte range in the source text,
+// so edits can be applied in place without re-marshalling the document.
+type jsonNode struct {
+	kind    byte // '{', '[' or 'v' for scalars
+	start   int
+	end     int
+	members []jsonMember
+	items   []*jsonNode
+}
+
+type jsonMember struct {
+	key      string
+	keyStart int
+	keyEnd   int
+	value    *jsonNode
+}
+
+type jsonScanner struct {
+	data string
+	pos  int
+}
+
+// parseLocatedJSON parses data into a tree of jsonNode.
+func parseLocatedJSON(data string) (*jsonNode, error) {
+	s := &jsonScanner{data: data}
+	s.skipSpace()
+	node, err := s.value()
+	if err != nil {
+		return nil, err
+	}
+	s.skipSpace()
+	if s.pos != len(s.data) {
+		return nil, fmt.Errorf("unexpected data at offset %d", s.pos)
+	}
+	return node, nil
+}
+
+func (s *jsonScanner) skipSpace() {
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n", s.data[s.pos]) >= 0 {
+		s.pos++
+	}
+}
+
+func (s *jsonScanner) value() (*jsonNode, error) {
+	if s.pos >= len(s.data) {
+		return nil, fmt.Errorf("unexpected end of JSON")
+	}
+	switch s.data[s.pos] {
+	case '{':
+		return s.object()
+	case '[':
+		return s.array()
+	case '"':
+		start := s.pos
+		if err := s.skipString(); err != nil {
+			return nil, err
+		}
+		return &jsonNode{kind: 'v', start: start, end: s.pos}, nil
+	}
+
+	start := s.pos
+	for s.pos < len(s.data) && strings.IndexByte(" \t\r\n,]}", s.data[s.pos]) < 0 {
+		s.pos++
+	}
+	if !json.Valid([]byte(s.data[start:s.pos])) {
+		return nil, fmt.Errorf("invalid value at offset %d", start)
+	}
+	return &jsonNode{kind: 'v', start: start, end: s.pos}, nil
+}
+
+func (s *jsonScanner) skipString() error {
+	s.pos++ // opening quote
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '\\':
+			s.pos += 2
+		case '"':
+			s.pos++
+			return nil
+		default:
+			s.pos++
+		}
+	}
+	return fmt.Errorf("unterminated string")
+}
+
+func (s *jsonScanner) object() (*jsonNode, error) {
+	node := &jsonNode{kind: '{', start: s.pos}
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == '}' {
+		s.pos++
+		node.end = s.pos
+		return node, nil
+	}
+
+	for {
+		if s.pos >= len(s.data) || s.data[s.pos] != '"' {
+			return nil, fmt.Errorf("expected object key at offset %d", s.pos)
+		}
+		keyStart := s.pos
+		if err := s.skipString(); err != nil {
+			return nil, err
+		}
+		keyEnd := s.pos
+		var key string
+		if err := json.Unmarshal([]byte(s.data[keyStart:keyEnd]), &key); err != nil {
+			return nil, fmt.Errorf("invalid object key at offset %d", keyStart)
+		}
+
+		s.skipSpace()
+		if s.pos >= len(s.data) || s.data[s.pos] != ':' {
+			return nil, fmt.Errorf("expected ':' at offset %d", s.pos)
+		}
+		s.pos++
+		s.skipSpace()
+		val, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		node.members = append(node.members, jsonMember{key: key, keyStart: keyStart, keyEnd: keyEnd, value: val})
+
+		s.skipSpace()
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			s.skipSpace()
+			continue
+		}
+		if s.pos < len(s.data) && s.data[s.pos] == '}' {
+			s.pos++
+			node.end = s.pos
+			return node, nil
+		}
+		return nil, fmt.Errorf("expected ',' or '}' at offset %d", s.pos)
+	}
+}
+
+func (s *jsonScanner) array() (*jsonNode, error) {
+	node := &jsonNode{kind: '[', start: s.pos}
+	s.pos++
+	s.skipSpace()
+	if s.pos < len(s.data) && s.data[s.pos] == ']' {
+		s.pos++
+		node.end = s.pos
+		return node, nil
+	}
+
+	for {
+		val, err := s.value()
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, val)
+
+		s.skipSpace()
+		if s.pos < len(s.data) && s.data[s.pos] == ',' {
+			s.pos++
+			s.skipSpace()
+			continue
+		}
+		if s.pos < len(s.data) && s.data[s.pos] == ']' {
+			s.pos++
+			node.end = s.pos
+			return node, nil
+		}
+		return nil, fmt.Errorf("expected ',' or ']' at offset %d", s.pos)
+	}
+}
+
+// jsonEdit replaces data[start:end] with text.
+type jsonEdit struct {
+	start int
+	end   int
+	text  string
+}
+
+// applyJSONEdits applies non-overlapping edits to data.
+func applyJSONEdits(data string, edits []jsonEdit) string {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	for _, e := range edits {
+		data = data[:e.start] + e.text + data[e.end:]
+	}
+	return data
+}
+
+// jsonEditor computes in-place edits for a JSONPath. Existing formatting,
+// key order and untouched values are kept byte for byte; inserted members
+// copy the indentation of their siblings.
+type jsonEditor struct {
+	data string
+}
+
+// set replaces every value matched by steps with raw. With create, missing
+// object keys are added (building intermediate objects as needed) and an
+// array index equal to the array length appends an item.
+func (e jsonEditor) set(node *jsonNode, steps []jsonPathStep, raw string, create bool) []jsonEdit {
+	if len(steps) == 0 {
+		return []jsonEdit{{start: node.start, end: node.end, text: raw}}
+	}
+	step, rest := steps[0], steps[1:]
+
+	var edits []jsonEdit
+	switch node.kind {
+	case '{':
+		if step.isIndex {
+			return nil
+		}
+		found := false
+		for _, m := range node.members {
+			if step.wildcard || m.key == step.key {
+				edits = append(edits, e.set(m.value, rest, raw, create)...)
+				found = true
+			}
+		}
+		if !found && create && !step.wildcard {
+			if val, ok := buildJSONValue(rest, raw); ok {
+				edits = append(edits, e.insert(node, quoteJSON(step.key), val))
+			}
+		}
+	case '[':
+		if step.wildcard {
+			for _, item := range node.items {
+				edits = append(edits, e.set(item, rest, raw, create)...)
+			}
+			return edits
+		}
+		if !step.isIndex {
+			return nil
+		}
+		idx := step.index
+		if idx < 0 {
+			idx += len(node.items)
+		}
+		if idx >= 0 && idx < len(node.items) {
+			return e.set(node.items[idx], rest, raw, create)
+		}
+		if create && idx == len(node.items) {
+			if val, ok := buildJSONValue(rest, raw); ok {
+				edits = append(edits, e.insert(node, "", val))
+			}
+		}
+	}
+	return edits
+}
+
+// remove deletes every object member or array item matched by steps.
+func (e jsonEditor) remove(node *jsonNode, steps []jsonPathStep) []jsonEdit {
+	if len(steps) == 0 {
+		return nil
+	}
+	step, rest := steps[0], steps[1:]
+
+	var edits []jsonEdit
+	switch node.kind {
+	case '{':
+		if step.isIndex {
+			return nil
+		}
+		if len(rest) == 0 {
+			if step.wildcard {
+				return []jsonEdit{{start: node.start + 1, end: node.end - 1}}
+			}
+			for i, m := range node.members {
+				if m.key == step.key {
+					edits = append(edits, e.removeMember(node, i))
+				}
+			}
+			return edits
+		}
+		for _, m := range node.members {
+			if step.wildcard || m.key == step.key {
+				edits = append(edits, e.remove(m.value, rest)...)
+			}
+		}
+	case '[':
+		if step.wildcard {
+			if len(rest) == 0 {
+				return []jsonEdit{{start: node.start + 1, end: node.end - 1}}
+			}
+			for _, item := range node.items {
+				edits = append(edits, e.remove(item, rest)...)
+			}
+			return edits
+		}
+		if !step.isIndex {
+			return nil
+		}
+		idx := step.index
+		if idx < 0 {
+			idx += len(node.items)
+		}
+		if idx < 0 || idx >= len(node.items) {
+			return nil
+		}
+		if len(rest) == 0 {
+			return []jsonEdit{e.removeItem(node, idx)}
+		}
+		return e.remove(node.items[idx], rest)
+	}
+	return edits
+}
+
+// insert appends a member (key != "") or an item (key == "") to a
+// container, after its last child.
+func (e jsonEditor) insert(node *jsonNode, key, val string) jsonEdit {
+	sep := ": "
+	starts := make([]int, 0, len(node.items)+len(node.members))
+	last := -1
+	for _, m := range node.members {
+		starts = append(starts, m.keyStart)
+		last = m.value.end
+		sep = e.data[m.keyEnd:m.value.start]
+	}
+	for _, item := range node.items {
+		starts = append(starts, item.start)
+		last = item.end
+	}
+
+	text := val
+	if key != "" {
+		text = key + sep + val
+	}
+	if len(starts) == 0 {
+		return jsonEdit{start: node.start + 1, end: node.end - 1, text: text}
+	}
+	lead := e.data[node.start+1 : starts[0]]
+	return jsonEdit{start: last, end: last, text: "," + lead + text}
+}
+
+func (e jsonEditor) removeMember(node *jsonNode, i int) jsonEdit {
+	switch {
+	case len(node.members) == 1:
+		return jsonEdit{start: node.start + 1, end: node.end - 1}
+	case i < len(node.members)-1:
+		return jsonEdit{start: node.members[i].keyStart, end: node.members[i+1].keyStart}
+	default:
+		return jsonEdit{start: node.members[i-1].value.end, end: node.members[i].value.end}
+	}
+}
+
+func (e jsonEditor) removeItem(node *jsonNode, i int) jsonEdit {
+	switch {
+	case len(node.items) == 1:
+		return jsonEdit{start: node.start + 1, end: node.end - 1}
+	case i < len(node.items)-1:
+		return jsonEdit{start: node.items[i].start, end: node.items[i+1].start}
+	default:
+		return jsonEdit{start: node.items[i-1].end, end: node.items[i].end}
+	}
+}
+
+// buildJSONValue wraps raw in the objects named by the remaining key steps,
+// e.g. [city] → {"city": raw}. Index and wildcard steps cannot be built.
+func buildJSONValue(steps []jsonPathStep, raw string) (string, bool) {
+	val := raw
+	for i := len(steps) - 1; i >= 0; i-- {
+		if steps[i].isIndex || steps[i].wildcard {
+			return "", false
+		}
+		val = "{" + quoteJSON(steps[i].key) + ": " + val + "}"
+	}
+	return val, true
+}
+
+func quoteJSON(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// jsonOverrideValue turns an override into JSON text: values that are
+// already valid JSON (numbers, true/false/null, objects, arrays, quoted
+// strings) are inserted as written, anything else as a string.
+func jsonOverrideValue(value string) string {
+	trimmed := strings.TrimSpace(value)
+	if trimmed != "" && json.Valid([]byte(trimmed)) {
+		return trimmed
+	}
+	return quoteJSON(value)
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is one segment of a JSONPath expression.
+type jsonPathStep struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool // `..` descent before this step
+}
+
+// parseJSONPath parses the JSONPath subset used across carmelia:
+// `$.a.b`, `$.items[0]`, `$.items[-1]`, `$.items[*].id`, `$['odd key']`
+// and `$..id`. The leading `$` is optional, so `address.city` and
+// `items[0].qty` are accepted too.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+
+	var steps []jsonPathStep
+	i := 0
+	for i < len(p) {
+		recursive := false
+		switch {
+		case strings.HasPrefix(p[i:], ".."):
+			recursive = true
+			i += 2
+		case p[i] == '.':
+			i++
+		}
+
+		if i >= len(p) {
+			return nil, fmt.Errorf("invalid JSONPath %q: trailing dot", path)
+		}
+
+		if p[i] == '[' {
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSONPath %q: unclosed bracket", path)
+			}
+			inner := strings.TrimSpace(p[i+1 : i+end])
+			i += end + 1
+
+			step := jsonPathStep{recursive: recursive}
+			switch {
+			case inner == "*":
+				step.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				step.key = inner[1 : len(inner)-1]
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: bad index %q", path, inner)
+				}
+				step.index = n
+				step.isIndex = true
+			}
+			steps = append(steps, step)
+			continue
+		}
+
+		end := i
+		for end < len(p) && p[end] != '.' && p[end] != '[' {
+			end++
+		}
+		name := p[i:end]
+		i = end
+		if name == "*" {
+			steps = append(steps, jsonPathStep{wildcard: true, recursive: recursive})
+		} else {
+			steps = append(steps, jsonPathStep{key: name, recursive: recursive})
+		}
+	}
+	return steps, nil
+}
+
+// evalJSONPath applies steps to a decoded JSON document and returns every
+// matching value.
+func evalJSONPath(doc any, steps []jsonPathStep) []any {
+	current := []any{doc}
+	for _, step := range steps {
+		var next []any
+		for _, node := range current {
+			if step.recursive {
+				for _, d := range jsonDescendants(node) {
+					next = append(next, applyJSONPathStep(d, step)...)
+				}
+				continue
+			}
+			next = append(next, applyJSONPathStep(node, step)...)
+		}
+		current = next
+	}
+	return current
+}
+
+func applyJSONPathStep(node any, step jsonPathStep) []any {
+	switch v := node.(type) {
+	case map[string]any:
+		if step.wildcard {
+			out := make([]any, 0, len(v))
+			for _, key := range sortedKeys(v) {
+				out = append(out, v[key])
+			}
+			return out
+		}
+		if step.isIndex {
+			return nil
+		}
+		if val, ok := v[step.key]; ok {
+			return []any{val}
+		}
+	case []any:
+		if step.wildcard {
+			return v
+		}
+		if step.isIndex {
+			idx := step.index
+			if idx < 0 {
+				idx += len(v)
+			}
+			if idx >= 0 && idx < len(v) {
+				return []any{v[idx]}
+			}
+		}
+	}
+	return nil
+}
+
+// jsonDescendants returns node and every value nested below it.
+func jsonDescendants(node any) []any {
+	out := []any{node}
+	switch v := node.(type) {
+	case map[string]any:
+		for _, k := range sortedKeys(v) {
+			out = append(out, jsonDescendants(v[k])...)
+		}
+	case []any:
+		for _, item := range v {
+			out = append(out, jsonDescendants(item)...)
+		}
+	}
+	return out
+}
+
+// decodeJSON decodes a JSON document keeping numbers as json.Number so
+// large IDs survive unchanged.
+func decodeJSON(data string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// QueryJSON evaluates a JSONPath expression against a JSON text.
+func QueryJSON(body, path string) ([]any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := decodeJSON(body)
+	if err != nil {
+		return nil, fmt.Errorf("body is not valid JSON: %w", err)
+	}
+	return evalJSONPath(doc, steps), nil
+}
+
+// formatJSONValue renders a JSONPath result for substitution: strings are
+// inserted as-is, everything else as compact JSON.
+func formatJSONValue(v any) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case nil:
+		return "null"
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
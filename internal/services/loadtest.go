@@ -0,0 +1,483 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxLoadConcurrency bounds the workers of a load test.
+const maxLoadConcurrency = 1000
+
+// maxLoadRPS bounds the target rate of a load test, which is paced by a
+// ticker.
+const maxLoadRPS = 100000
+
+// loadStatsInterval is how often live stats are reported.
+const loadStatsInterval = 500 * time.Millisecond
+
+// latencyBuckets are the upper bounds of the latency histogram, in
+// milliseconds.
+var latencyBuckets = []float64{1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// RunLoadTest sends one request of run.File repeatedly from cfg.Concurrency
+// workers. The request is resolved once, so built-ins such as {{$uuid}}
+// keep one value for the whole test; scripts, captures and assertions do
+// not run and nothing is recorded in history. progress, when set, receives
+// live stats. Cancelling ctx stops the test and aborts the requests in
+// flight, which are not counted.
+func RunLoadTest(ctx context.Context, run RequestRun, parsed models.ParsedHttpRequest, sets map[string]string, cfg models.LoadTestConfig, progress func(models.LoadTestStats)) (models.LoadTestReport, error) {
+	if err := checkLoadConfig(&cfg); err != nil {
+		return models.LoadTestReport{}, err
+	}
+	config, _ := LoadConfig(run.ProjectPath)
+	tlsConfig, err := EnvTLSConfig(run.ProjectPath, run.EnvName)
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+	exec, resolved, err := loadTarget(run, parsed, sets, config, tlsConfig, loadCookieJar(run.ProjectPath, run.EnvName))
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+
+	report := models.LoadTestReport{
+		Name:   parsed.Name,
+		Method: resolved.Method,
+		URL:    resolved.URL,
+		Env:    run.EnvName,
+		Config: cfg,
+	}
+	runLoad(ctx, cfg, []ExecuteOptions{exec}, &report, progress)
+	return report, nil
+}
+
+// RunFolderLoadTest load tests every request under folder (relative to
+// .carmelia/requests/, empty for all of them) like RunLoadTest: each worker
+// sends the folder's requests in turn, in file tree order, and every one
+// of them counts towards cfg.Requests and cfg.RPS.
+func RunFolderLoadTest(ctx context.Context, projectPath, folder, envName string, cfg models.LoadTestConfig, progress func(models.LoadTestStats)) (models.LoadTestReport, error) {
+	if err := checkLoadConfig(&cfg); err != nil {
+		return models.LoadTestReport{}, err
+	}
+	requests, err := collectFolder(projectPath, folder)
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+	if len(requests) == 0 {
+		return models.LoadTestReport{}, fmt.Errorf("no requests to load test")
+	}
+	config, _ := LoadConfig(projectPath)
+	tlsConfig, err := EnvTLSConfig(projectPath, envName)
+	if err != nil {
+		return models.LoadTestReport{}, err
+	}
+	jar := loadCookieJar(projectPath, envName)
+
+	report := models.LoadTestReport{
+		Folder: folder,
+		Env:    envName,
+		Config: cfg,
+	}
+	targets := make([]ExecuteOptions, 0, len(requests))
+	files := map[string][]models.ParsedHttpRequest{}
+	for _, req := range requests {
+		name := req.Name
+		if req.Folder != "" {
+			name = req.Folder + " / " + name
+		}
+		file, ok := files[req.Path]
+		if !ok {
+			content, err := ReadRequest(projectPath, req.Path)
+			if err != nil {
+				return models.LoadTestReport{}, fmt.Errorf("%s: %w", name, err)
+			}
+			file = ParseHttpFileAll(content)
+			files[req.Path] = file
+		}
+
+		run := RequestRun{
+			ProjectPath: projectPath,
+			EnvName:     envName,
+			File:        file,
+			FileKey:     req.Path,
+		}
+		exec, _, err := loadTarget(run, req.Parsed, map[string]string{}, config, tlsConfig, jar)
+		if err != nil {
+			return models.LoadTestReport{}, fmt.Errorf("%s: %w", name, err)
+		}
+		targets = append(targets, exec)
+		report.Requests = append(report.Requests, name)
+	}
+
+	runLoad(ctx, cfg, targets, &report, progress)
+	return report, nil
+}
+
+// checkLoadConfig validates cfg and applies its defaults.
+func checkLoadConfig(cfg *models.LoadTestConfig) error {
+	if cfg.Requests <= 0 && cfg.Duration <= 0 {
+		return fmt.Errorf("set a number of requests or a duration")
+	}
+	if cfg.Concurrency > maxLoadConcurrency {
+		return fmt.Errorf("concurrency cannot exceed %d", maxLoadConcurrency)
+	}
+	if cfg.RPS > maxLoadRPS {
+		return fmt.Errorf("rps cannot exceed %d", maxLoadRPS)
+	}
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.RPS < 0 {
+		cfg.RPS = 0
+	}
+	return nil
+}
+
+// loadCookieJar returns the jar load test requests share: a copy of the
+// env's jar, so the cookies they receive are not written back to it.
+func loadCookieJar(projectPath, envName string) *CookieJar {
+	if projectPath == "" {
+		return nil
+	}
+	return OpenCookieJar(projectPath, envName).Detached()
+}
+
+// loadTarget resolves a request of a load test into what each send uses.
+func loadTarget(run RequestRun, parsed models.ParsedHttpRequest, sets map[string]string, config models.HttxConfig, tlsConfig *tls.Config, jar *CookieJar) (ExecuteOptions, models.ParsedHttpRequest, error) {
+	resolved, unresolved := resolveLoadRequest(run, parsed, sets)
+	if len(unresolved) > 0 && config.Runner.OnUnresolved != "ignore" {
+		return ExecuteOptions{}, resolved, fmt.Errorf("unresolved variables: %s", FormatUnresolved(unresolved))
+	}
+	exec := ExecuteOptions{
+		Method:          resolved.Method,
+		URL:             resolved.URL,
+		Headers:         resolved.Headers,
+		Body:            resolved.Body,
+		Timeout:         config.Runner.Timeout,
+		FollowRedirects: config.Runner.FollowRedirects,
+		TLS:             tlsConfig,
+	}
+	if !parsed.NoCookieJar && jar != nil {
+		exec.Jar = jar
+	}
+	return exec, resolved, nil
+}
+
+// runLoad sends targets in turn from cfg.Concurrency workers until the
+// test ends, and fills in the start time, stats and cancellation of
+// report.
+func runLoad(ctx context.Context, cfg models.LoadTestConfig, targets []ExecuteOptions, report *models.LoadTestReport, progress func(models.LoadTestStats)) {
+	var testCtx context.Context
+	var cancel context.CancelFunc
+	if cfg.Duration > 0 {
+		testCtx, cancel = context.WithTimeout(ctx, time.Duration(cfg.Duration)*time.Second)
+	} else {
+		testCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	var pace <-chan time.Time
+	if cfg.RPS > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.RPS))
+		defer ticker.Stop()
+		pace = ticker.C
+	}
+
+	stats := newLoadStats()
+	report.StartedAt = stats.started.UnixMilli()
+
+	var issued atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if pace != nil {
+					select {
+					case <-testCtx.Done():
+						return
+					case <-pace:
+					}
+				} else if testCtx.Err() != nil {
+					return
+				}
+				n := issued.Add(1)
+				if cfg.Requests > 0 && n > int64(cfg.Requests) {
+					return
+				}
+
+				start := time.Now()
+				resp, err := ExecuteRequest(testCtx, targets[(n-1)%int64(len(targets))])
+				if err != nil && testCtx.Err() != nil {
+					return
+				}
+				stats.record(time.Since(start), resp.Status, err)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	ticker := time.NewTicker(loadStatsInterval)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		case <-ticker.C:
+			if progress != nil {
+				progress(stats.snapshot())
+			}
+		}
+	}
+
+	report.Cancelled = ctx.Err() != nil
+	report.Stats = stats.snapshot()
+	if progress != nil {
+		progress(report.Stats)
+	}
+}
+
+// resolveLoadRequest resolves a request as RunRequest would, running the
+// named requests it references when history has no response for them.
+func resolveLoadRequest(run RequestRun, parsed models.ParsedHttpRequest, sets map[string]string) (models.ParsedHttpRequest, []models.UnresolvedVariable) {
+	env := models.EnvVariables{}
+	if run.EnvName != "" && run.ProjectPath != "" {
+		if loaded, err := LoadEnv(run.ProjectPath, run.EnvName); err == nil {
+			env = loaded
+		}
+	}
+
+	var visiting []string
+	if parsed.Name != "" {
+		visiting = []string{parsed.Name}
+	}
+	return ResolveRequest(parsed, ResolveOptions{
+		Env:     env,
+		Sets:    sets,
+		Data:    run.Data,
+		Runtime: RuntimeValues(run.ProjectPath, run.EnvName),
+		Responses: NamedResponseLookup(run.ProjectPath, run.File, run.FileKey, visiting,
+			func(named NamedRequest) (models.RunResult, error) {
+				return runRequest(run, named.Request, named.HistoryKey, map[string]string{}, visiting), nil
+			}),
+		Dynamic:     DynamicValues{},
+		ProjectPath: run.ProjectPath,
+	})
+}
+
+// Percentiles are read from a histogram of logarithmic buckets, each
+// latencyGrowth times wider than the previous one, so they are within 1%
+// of the exact value whatever the number of requests. Bucket i holds the
+// latencies from latencyGrowth^(i-1) to latencyGrowth^i microseconds; the
+// last one also holds everything slower (about 45 minutes).
+const (
+	latencyGrowth     = 1.02
+	latencyBucketsLen = 1100
+)
+
+var logLatencyGrowth = math.Log(latencyGrowth)
+
+// loadStats collects the outcome of every request of a load test.
+type loadStats struct {
+	mu       sync.Mutex
+	started  time.Time
+	count    int
+	total    time.Duration
+	min, max time.Duration
+	buckets  [latencyBucketsLen]int
+	display  []int // counts per latencyBuckets bound, plus slower
+	statuses map[string]int
+	errors   map[string]int
+	failed   int
+}
+
+func newLoadStats() *loadStats {
+	return &loadStats{
+		started:  time.Now(),
+		display:  make([]int, len(latencyBuckets)+1),
+		statuses: map[string]int{},
+		errors:   map[string]int{},
+	}
+}
+
+func (s *loadStats) record(latency time.Duration, status int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors[loadErrorType(err)]++
+		s.failed++
+		return
+	}
+	if s.count == 0 || latency < s.min {
+		s.min = latency
+	}
+	if latency > s.max {
+		s.max = latency
+	}
+	s.count++
+	s.total += latency
+	s.buckets[latencyBucket(latency)]++
+	s.display[sort.SearchFloat64s(latencyBuckets, durationMs(latency))]++
+	s.statuses[strconv.Itoa(status)]++
+	if status >= 400 {
+		s.failed++
+	}
+}
+
+func (s *loadStats) snapshot() models.LoadTestStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := models.LoadTestStats{
+		Elapsed:   time.Since(s.started).Milliseconds(),
+		Completed: s.count,
+		Failed:    s.failed,
+		Statuses:  make(map[string]int, len(s.statuses)),
+		Errors:    make(map[string]int, len(s.errors)),
+		Histogram: make([]models.LatencyBucket, len(s.display)),
+	}
+	for k, v := range s.statuses {
+		stats.Statuses[k] = v
+	}
+	for k, v := range s.errors {
+		stats.Errors[k] = v
+		stats.Completed += v
+	}
+	for i, count := range s.display {
+		stats.Histogram[i].Count = count
+		if i < len(latencyBuckets) {
+			stats.Histogram[i].UpperBound = latencyBuckets[i]
+		}
+	}
+
+	if stats.Elapsed > 0 {
+		stats.Throughput = float64(stats.Completed) / (float64(stats.Elapsed) / 1000)
+	}
+	if stats.Completed > 0 {
+		stats.ErrorRate = float64(stats.Failed) / float64(stats.Completed)
+	}
+	if s.count == 0 {
+		return stats
+	}
+
+	stats.Latency = models.LatencySummary{
+		Min:  durationMs(s.min),
+		Mean: durationMs(s.total / time.Duration(s.count)),
+		P50:  durationMs(s.percentile(50)),
+		P90:  durationMs(s.percentile(90)),
+		P99:  durationMs(s.percentile(99)),
+		Max:  durationMs(s.max),
+	}
+	return stats
+}
+
+// percentile returns the nearest-rank percentile p of the recorded
+// latencies: the upper bound of the bucket holding it, kept within the
+// observed min and max.
+func (s *loadStats) percentile(p int) time.Duration {
+	rank := (p*s.count + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	seen := 0
+	for i, count := range s.buckets {
+		if seen += count; seen >= rank {
+			bound := time.Duration(math.Pow(latencyGrowth, float64(i))) * time.Microsecond
+			return min(max(bound, s.min), s.max)
+		}
+	}
+	return s.max
+}
+
+// latencyBucket returns the histogram bucket of a latency.
+func latencyBucket(latency time.Duration) int {
+	us := latency.Microseconds()
+	if us < 1 {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(us)) / logLatencyGrowth))
+	return min(max(i, 0), latencyBucketsLen-1)
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// loadErrorType groups the errors of ExecuteRequest for the load test
+// stats.
+func loadErrorType(err error) string {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "timed out"), strings.Contains(msg, "Timeout"):
+		return "timeout"
+	case strings.Contains(msg, "connection refused"):
+		return "connection refused"
+	case strings.Contains(msg, "connection reset"):
+		return "connection reset"
+	case strings.Contains(msg, "no such host"):
+		return "dns"
+	case strings.Contains(msg, "tls:"), strings.Contains(msg, "x509:"):
+		return "tls"
+	case strings.Contains(msg, "EOF"):
+		return "eof"
+	default:
+		return "other"
+	}
+}
+
+var reportNameRegex = regexp.MustCompile(`[^\w-]+`)
+
+// folderReportName is the file name of a folder load test report.
+const folderReportName = "folder.load.json"
+
+// LoadTestReportPath returns where the load test report of a request is
+// saved, relative to .carmelia/requests/: next to its .http file, as
+// <file>.load.json, or <file>.<name>.load.json for a named request. The
+// report of a folder test is saved in the folder as folder.load.json.
+func LoadTestReportPath(requestPath string, report models.LoadTestReport) string {
+	if len(report.Requests) > 0 {
+		return filepath.Join(filepath.FromSlash(report.Folder), folderReportName)
+	}
+	base := strings.TrimSuffix(requestPath, ".http")
+	if name := reportNameRegex.ReplaceAllString(report.Name, "-"); name != "" {
+		base += "." + name
+	}
+	return base + ".load.json"
+}
+
+// SaveLoadTestReport writes a load test report as JSON next to the request
+// file it was run from, or in the folder of a folder test (requestPath is
+// then unused), and returns its path relative to .carmelia/requests/.
+func SaveLoadTestReport(projectPath, requestPath string, report models.LoadTestReport) (string, error) {
+	if len(report.Requests) == 0 && !strings.HasSuffix(requestPath, ".http") {
+		return "", fmt.Errorf("save the request to a file first")
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	relPath := LoadTestReportPath(requestPath, report)
+	fullPath := filepath.Join(projectPath, ".carmelia", "requests", relPath)
+	if err := os.WriteFile(fullPath, append(data, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("failed to save report: %w", err)
+	}
+	return relPath, nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckLoadConfig(t *testing.T) {
+	tests := []struct {
+		cfg     models.LoadTestConfig
+		want    models.LoadTestConfig
+		wantErr bool
+	}{
+		{cfg: models.LoadTestConfig{Requests: 10}, want: models.LoadTestConfig{Concurrency: 1, Requests: 10}},
+		{cfg: models.LoadTestConfig{Duration: 5, RPS: -1}, want: models.LoadTestConfig{Concurrency: 1, Duration: 5}},
+		{cfg: models.LoadTestConfig{Concurrency: 8, Requests: 10, RPS: maxLoadRPS}, want: models.LoadTestConfig{Concurrency: 8, Requests: 10, RPS: maxLoadRPS}},
+		{cfg: models.LoadTestConfig{Concurrency: 8}, wantErr: true},
+		{cfg: models.LoadTestConfig{Requests: 10, Concurrency: maxLoadConcurrency + 1}, wantErr: true},
+		{cfg: models.LoadTestConfig{Requests: 10, RPS: maxLoadRPS + 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		cfg := tt.cfg
+		err := checkLoadConfig(&cfg)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("checkLoadConfig(%+v) error = %v, wantErr %v", tt.cfg, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && cfg != tt.want {
+			t.Errorf("checkLoadConfig(%+v) = %+v, want %+v", tt.cfg, cfg, tt.want)
+		}
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	tests := []struct {
+		latency time.Duration
+		want    int
+	}{
+		{0, 0},
+		{time.Nanosecond, 0},
+		{time.Microsecond, 0},
+		{time.Hour, latencyBucketsLen - 1},
+	}
+	for _, tt := range tests {
+		if got := latencyBucket(tt.latency); got != tt.want {
+			t.Errorf("latencyBucket(%v) = %d, want %d", tt.latency, got, tt.want)
+		}
+	}
+
+	// Every latency is at most its bucket's upper bound and above the
+	// previous one
+	for _, d := range []time.Duration{2 * time.Microsecond, 999 * time.Microsecond, 12 * time.Millisecond, 3 * time.Second, 40 * time.Minute} {
+		i := latencyBucket(d)
+		upper := math.Pow(latencyGrowth, float64(i))
+		lower := math.Pow(latencyGrowth, float64(i-1))
+		if us := float64(d.Microseconds()); us > upper || us <= lower {
+			t.Errorf("latencyBucket(%v) = %d, bounds %.0f-%.0f µs", d, i, lower, upper)
+		}
+	}
+}
+
+func TestLoadStatsPercentiles(t *testing.T) {
+	s := newLoadStats()
+	for ms := 1; ms <= 1000; ms++ {
+		s.record(time.Duration(ms)*time.Millisecond, 200, nil)
+	}
+	tests := []struct {
+		p    int
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{50, 500 * time.Millisecond},
+		{90, 900 * time.Millisecond},
+		{99, 990 * time.Millisecond},
+		{100, time.Second},
+	}
+	for _, tt := range tests {
+		got := s.percentile(tt.p)
+		if diff := math.Abs(float64(got-tt.want)) / float64(tt.want); diff > latencyGrowth-1 {
+			t.Errorf("percentile(%d) = %v, want %v within %.0f%%", tt.p, got, tt.want, (latencyGrowth-1)*100)
+		}
+	}
+
+	// A single sample is reported exactly
+	one := newLoadStats()
+	one.record(123*time.Millisecond, 200, nil)
+	if got := one.percentile(99); got != 123*time.Millisecond {
+		t.Errorf("percentile of one sample = %v", got)
+	}
+}
+
+func TestLoadStatsSnapshot(t *testing.T) {
+	s := newLoadStats()
+	s.record(3*time.Millisecond, 200, nil)
+	s.record(40*time.Millisecond, 200, nil)
+	s.record(20*time.Second, 503, nil)
+	s.record(0, 0, errors.New("dial tcp: connection refused"))
+
+	stats := s.snapshot()
+	if stats.Completed != 4 || stats.Failed != 2 || stats.ErrorRate != 0.5 {
+		t.Errorf("completed %d, failed %d, error rate %v", stats.Completed, stats.Failed, stats.ErrorRate)
+	}
+	if stats.Statuses["200"] != 2 || stats.Statuses["503"] != 1 || stats.Errors["connection refused"] != 1 {
+		t.Errorf("statuses %v, errors %v", stats.Statuses, stats.Errors)
+	}
+	if stats.Latency.Min != 3 || stats.Latency.Max != 20000 {
+		t.Errorf("latency = %+v", stats.Latency)
+	}
+
+	counts := map[float64]int{}
+	for _, b := range stats.Histogram {
+		counts[b.UpperBound] += b.Count
+	}
+	if counts[5] != 1 || counts[50] != 1 || counts[0] != 1 {
+		t.Errorf("histogram = %+v", stats.Histogram)
+	}
+}
+
+func TestLoadErrorType(t *testing.T) {
+	tests := []struct{ msg, want string }{
+		{"request timed out after 30s", "timeout"},
+		{"net/http: TLS handshake Timeout", "timeout"},
+		{"dial tcp 127.0.0.1:1: connect: connection refused", "connection refused"},
+		{"read: connection reset by peer", "connection reset"},
+		{"dial tcp: lookup nope.invalid: no such host", "dns"},
+		{"tls: failed to verify certificate: x509: unknown authority", "tls"},
+		{"unexpected EOF", "eof"},
+		{"something else", "other"},
+	}
+	for _, tt := range tests {
+		if got := loadErrorType(errors.New(tt.msg)); got != tt.want {
+			t.Errorf("loadErrorType(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestLoadTestReportPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		report models.LoadTestReport
+		want   string
+	}{
+		{"users/list.http", models.LoadTestReport{}, "users/list.load.json"},
+		{"users/all.http", models.LoadTestReport{Name: "get user"}, "users/all.get-user.load.json"},
+		{"", models.LoadTestReport{Folder: "users/admin", Requests: []string{"list.http"}}, filepath.Join("users", "admin", folderReportName)},
+		{"", models.LoadTestReport{Requests: []string{"ping.http"}}, folderReportName},
+	}
+	for _, tt := range tests {
+		if got := LoadTestReportPath(tt.path, tt.report); got != tt.want {
+			t.Errorf("LoadTestReportPath(%q, %+v) = %q, want %q", tt.path, tt.report, got, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // OrderMap maps a directory relative path (empty string = root) to an ordered list of child names.
@@ -44,3 +45,40 @@ func SaveOrder(projectPath string, order OrderMap) error {
 	}
 	return os.WriteFile(orderFilePath(projectPath), data, 0o644)
 }
+
+// appendOrder lists new items after the others of their directory, keyed
+// as in OrderMap. A directory without an order yet first lists its other
+// items alphabetically, as the file tree shows them.
+func appendOrder(projectPath string, added map[string][]string) error {
+	if len(added) == 0 {
+		return nil
+	}
+	order, _ := LoadOrder(projectPath)
+	requestsDir := filepath.Join(projectPath, ".carmelia", "requests")
+	for dir, names := range added {
+		isNew := make(map[string]bool, len(names))
+		for _, name := range names {
+			isNew[name] = true
+		}
+		list, ok := order[dir]
+		if !ok {
+			entries, _ := os.ReadDir(filepath.Join(requestsDir, dir))
+			for _, e := range entries {
+				if (e.IsDir() || strings.HasSuffix(e.Name(), ".http")) && !isNew[e.Name()] {
+					list = append(list, e.Name())
+				}
+			}
+		}
+		for _, name := range list {
+			delete(isNew, name)
+		}
+		for _, name := range names {
+			if isNew[name] {
+				list = append(list, name)
+				delete(isNew, name)
+			}
+		}
+		order[dir] = list
+	}
+	return SaveOrder(projectPath, order)
+}
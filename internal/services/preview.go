@@ -0,0 +1,46 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"regexp"
+	"strings"
+)
+
+// secretNameRegex matches variable names whose values are masked in
+// previews.
+var secretNameRegex = regexp.MustCompile(`(?i)(secret|passw(or)?d|token|api[_-]?key|credential|private)`)
+
+// sensitiveHeaders have their substituted values masked in previews.
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"x-api-key":           true,
+}
+
+const maskedValue = "••••••"
+
+// PreviewRequest resolves req like ResolveRequest and reports where every
+// placeholder's value came from. Unlocked secrets, values of secret-looking
+// variables and of sensitive headers are masked in the trace and in the
+// resolved request, wherever they were inserted.
+func PreviewRequest(req models.ParsedHttpRequest, opts ResolveOptions) models.ResolvePreview {
+	if opts.Secrets == nil {
+		opts.Secrets = projectSecrets(opts.ProjectPath)
+	}
+	trace := []models.VariableTrace{}
+	masked := maskedRequest{sensitive: true}
+	_, unresolved := resolveRequest(req, opts, &trace, &masked)
+
+	return models.ResolvePreview{
+		Request:    masked.request,
+		Variables:  trace,
+		Unresolved: unresolved,
+	}
+}
+
+// isSensitiveField reports whether a resolver field is a sensitive header.
+func isSensitiveField(field string) bool {
+	header, ok := strings.CutPrefix(field, "header ")
+	return ok && sensitiveHeaders[strings.ToLower(header)]
+}
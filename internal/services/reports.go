@@ -0,0 +1,244 @@
+package services
+
+import (
+	"bytes"
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+// FormatCollectionReport renders a collection run as "json", "junit" (JUnit
+// XML, one test case per request) or "html" (a standalone page).
+func FormatCollectionReport(report models.CollectionReport, format string) (string, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case "junit":
+		return junitReport(report)
+	case "html":
+		return htmlReport(report)
+	default:
+		return "", fmt.Errorf("unsupported report format: %s", format)
+	}
+}
+
+// ReportFilename returns the default filename of a report.
+func ReportFilename(report models.CollectionReport, format string) string {
+	name := report.Project
+	if report.Folder != "" {
+		name += "-" + strings.ReplaceAll(report.Folder, "/", "-")
+	}
+	safe := strings.ReplaceAll(strings.ToLower(name), " ", "-")
+	switch format {
+	case "junit":
+		return safe + ".junit.xml"
+	case "html":
+		return safe + ".report.html"
+	default:
+		return safe + ".report.json"
+	}
+}
+
+// ReportFileFilter returns a Wails file filter for a report format.
+func ReportFileFilter(format string) (string, string) {
+	switch format {
+	case "junit":
+		return "XML Files (*.xml)", "*.xml"
+	case "html":
+		return "HTML Files (*.html)", "*.html"
+	default:
+		return "JSON Files (*.json)", "*.json"
+	}
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitReport groups test cases into one suite per folder. A request that
+// could not be sent is an error; a failed check is a failure.
+func junitReport(report models.CollectionReport) (string, error) {
+	suites := junitSuites{
+		Name:  report.Project,
+		Tests: len(report.Items),
+		Time:  junitSeconds(report.Duration),
+	}
+	timestamp := time.UnixMilli(report.StartedAt).UTC().Format("2006-01-02T15:04:05")
+
+	index := map[string]int{}
+	var elapsed []int64
+	for _, item := range report.Items {
+		folder := item.Folder
+		if folder == "" {
+			folder = report.Project
+		}
+		i, ok := index[folder]
+		if !ok {
+			i = len(suites.Suites)
+			index[folder] = i
+			suites.Suites = append(suites.Suites, junitSuite{Name: folder, Timestamp: timestamp})
+			elapsed = append(elapsed, 0)
+		}
+		suite := &suites.Suites[i]
+		elapsed[i] += item.Time
+
+		name := item.Name
+		if item.Iteration > 0 {
+			name = fmt.Sprintf("%s [iteration %d]", name, item.Iteration)
+		}
+		tc := junitCase{
+			Name:      name,
+			ClassName: strings.ReplaceAll(folder, "/", "."),
+			Time:      junitSeconds(item.Time),
+			SystemOut: fmt.Sprintf("%s %s → %s", item.Method, item.URL, item.StatusText),
+		}
+		switch {
+		case item.Error != "":
+			tc.Error = &junitProblem{Message: item.Error, Text: item.Error}
+			suite.Errors++
+			suites.Errors++
+		case !item.Passed:
+			tc.Failure = &junitProblem{Message: item.Failures[0], Text: strings.Join(item.Failures, "\n")}
+			suite.Failures++
+			suites.Failures++
+		}
+		suite.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+	for i := range suites.Suites {
+		suites.Suites[i].Time = junitSeconds(elapsed[i])
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(data) + "\n", nil
+}
+
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(ms int64) string {
+		return time.UnixMilli(ms).Format("2006-01-02 15:04:05")
+	},
+	// Assertions are listed on their own
+	"otherFailures": func(failures []string) []string {
+		var out []string
+		for _, f := range failures {
+			if !strings.HasPrefix(f, assertionFailurePrefix) {
+				out = append(out, f)
+			}
+		}
+		return out
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Project}}{{if .Folder}} / {{.Folder}}{{end}} — run report</title>
+<style>
+  body { font: 14px/1.5 system-ui, sans-serif; margin: 2rem; color: #1f2937; background: #f9fafb; }
+  h1 { font-size: 1.25rem; margin: 0 0 .25rem; }
+  .meta { color: #6b7280; margin-bottom: 1.5rem; }
+  .summary span { display: inline-block; margin-right: 1rem; font-weight: 600; }
+  .pass { color: #15803d; } .fail { color: #b91c1c; }
+  table { width: 100%; border-collapse: collapse; background: #fff; margin-top: 1rem; }
+  th, td { text-align: left; padding: .5rem .75rem; border-bottom: 1px solid #e5e7eb; vertical-align: top; }
+  th { background: #f3f4f6; font-weight: 600; }
+  code { font: 12px ui-monospace, monospace; word-break: break-all; }
+  ul { margin: .25rem 0 0; padding-left: 1.1rem; }
+  .method { font-weight: 700; }
+</style>
+</head>
+<body>
+<h1>{{.Project}}{{if .Folder}} / {{.Folder}}{{end}}</h1>
+<div class="meta">{{date .StartedAt}}{{if .Env}} · env <b>{{.Env}}</b>{{end}}{{if .DataFile}} · data <b>{{.DataFile}}</b>{{end}} · {{.Duration}} ms{{if .Cancelled}} · cancelled{{end}}</div>
+<div class="summary">
+  <span>{{.Total}} requests</span>
+  <span class="pass">{{.Passed}} passed</span>
+  <span class="fail">{{.Failed}} failed</span>
+</div>
+{{if .Iterations}}
+<table>
+  <thead><tr><th>Iteration</th><th>Data</th><th>Passed</th><th>Failed</th></tr></thead>
+  <tbody>
+  {{range .Iterations}}
+  <tr>
+    <td class="{{if .Failed}}fail{{else}}pass{{end}}">#{{.Index}}</td>
+    <td><code>{{range $k, $v := .Data}}{{$k}}={{$v}} {{end}}</code></td>
+    <td>{{.Passed}}</td>
+    <td>{{.Failed}}</td>
+  </tr>
+  {{end}}
+  </tbody>
+</table>
+{{end}}
+<table>
+  <thead><tr><th></th><th>Request</th><th>Status</th><th>Time</th><th>Checks</th></tr></thead>
+  <tbody>
+  {{range .Items}}
+  <tr>
+    <td class="{{if .Passed}}pass{{else}}fail{{end}}">{{if .Passed}}✓{{else}}✗{{end}}</td>
+    <td><div>{{if .Folder}}{{.Folder}} / {{end}}{{.Name}}{{if .Iteration}} <span class="meta">#{{.Iteration}}</span>{{end}}</div><code><span class="method">{{.Method}}</span> {{.URL}}</code></td>
+    <td>{{if .Error}}<span class="fail">{{.Error}}</span>{{else}}{{.StatusText}}{{end}}</td>
+    <td>{{.Time}} ms</td>
+    <td>
+      {{if .Assertions}}<ul>{{range .Assertions}}<li class="{{if .Passed}}pass{{else}}fail{{end}}"><code>{{.Expr}}</code>{{if .Error}} — {{.Error}}{{else if not .Passed}} — got <code>{{.Actual}}</code>{{end}}</li>{{end}}</ul>{{end}}
+      {{with otherFailures .Failures}}<ul>{{range .}}<li class="fail">{{.}}</li>{{end}}</ul>{{end}}
+    </td>
+  </tr>
+  {{end}}
+  </tbody>
+</table>
+</body>
+</html>
+`))
+
+func htmlReport(report models.CollectionReport) (string, error) {
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sampleReport() models.CollectionReport {
+	return models.CollectionReport{
+		Project:   "Shop API",
+		Folder:    "orders/v2",
+		Env:       "staging",
+		StartedAt: 1700000000000,
+		Duration:  1500,
+		Total:     3,
+		Passed:    1,
+		Failed:    2,
+		Items: []models.CollectionItemResult{
+			{Folder: "orders", Name: "list", Method: "GET", URL: "https://x/orders", StatusText: "200 OK", Time: 250, Passed: true},
+			{
+				Folder: "orders", Name: "create <new>", Method: "POST", URL: "https://x/orders", StatusText: "500 Internal Server Error", Time: 750,
+				Assertions: []models.AssertionResult{{Expr: "status == 201", Actual: "500"}},
+				Failures:   []string{assertionFailurePrefix + "status == 201", "capture id: no match"},
+			},
+			{Name: "health", Method: "GET", URL: "https://x/health", Time: 500, Error: "connection refused", Iteration: 2},
+		},
+	}
+}
+
+func TestFormatCollectionReportJSON(t *testing.T) {
+	report := sampleReport()
+	out, err := FormatCollectionReport(report, "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded models.CollectionReport
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, report) {
+		t.Errorf("round trip = %+v, want %+v", decoded, report)
+	}
+}
+
+func TestFormatCollectionReportJUnit(t *testing.T) {
+	out, err := FormatCollectionReport(sampleReport(), "junit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, xml.Header) {
+		t.Error("missing XML header")
+	}
+
+	var suites junitSuites
+	if err := xml.Unmarshal([]byte(out), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 3 || suites.Failures != 1 || suites.Errors != 1 || suites.Time != "1.500" {
+		t.Errorf("totals = %d tests, %d failures, %d errors, %s s", suites.Tests, suites.Failures, suites.Errors, suites.Time)
+	}
+
+	tests := []struct {
+		suite, time string
+		cases       []string
+	}{
+		{"orders", "1.000", []string{"list", "create <new>"}},
+		{"Shop API", "0.500", []string{"health [iteration 2]"}},
+	}
+	if len(suites.Suites) != len(tests) {
+		t.Fatalf("got %d suites, want %d", len(suites.Suites), len(tests))
+	}
+	for i, tt := range tests {
+		suite := suites.Suites[i]
+		var names []string
+		for _, c := range suite.Cases {
+			names = append(names, c.Name)
+		}
+		if suite.Name != tt.suite || suite.Time != tt.time || !reflect.DeepEqual(names, tt.cases) {
+			t.Errorf("suite %d = %q %s %v, want %q %s %v", i, suite.Name, suite.Time, names, tt.suite, tt.time, tt.cases)
+		}
+		if suite.Timestamp != "2023-11-14T22:13:20" {
+			t.Errorf("suite %d timestamp = %q", i, suite.Timestamp)
+		}
+	}
+
+	failed := suites.Suites[0].Cases[1]
+	if failed.Failure == nil || failed.Failure.Message != assertionFailurePrefix+"status == 201" {
+		t.Errorf("failure = %+v", failed.Failure)
+	}
+	if !strings.Contains(failed.Failure.Text, "capture id: no match") {
+		t.Errorf("failure text = %q", failed.Failure.Text)
+	}
+	if errored := suites.Suites[1].Cases[0]; errored.Error == nil || errored.Error.Message != "connection refused" {
+		t.Errorf("error = %+v", errored.Error)
+	}
+}
+
+func TestFormatCollectionReportHTML(t *testing.T) {
+	report := sampleReport()
+	report.DataFile = "users.csv"
+	report.Iterations = []models.IterationSummary{{Index: 1, Data: map[string]string{"user": "ada"}, Passed: 1, Failed: 2}}
+	out, err := FormatCollectionReport(report, "html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>Shop API / orders/v2 — run report</title>",
+		"data <b>users.csv</b>",
+		"user=ada",
+		`<span class="meta">#2</span>`,
+		"create &lt;new&gt;",
+		"<code>status == 201</code> — got <code>500</code>",
+		`<li class="fail">capture id: no match</li>`,
+		"connection refused",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML report is missing %q", want)
+		}
+	}
+	// Assertion failures are listed with the assertions only
+	if strings.Contains(out, assertionFailurePrefix) {
+		t.Error("assertion failure repeated in the failure list")
+	}
+}
+
+func TestFormatCollectionReportUnknown(t *testing.T) {
+	if _, err := FormatCollectionReport(sampleReport(), "pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestReportFilename(t *testing.T) {
+	report := sampleReport()
+	tests := []struct{ format, want string }{
+		{"json", "shop-api-orders-v2.report.json"},
+		{"junit", "shop-api-orders-v2.junit.xml"},
+		{"html", "shop-api-orders-v2.report.html"},
+	}
+	for _, tt := range tests {
+		if got := ReportFilename(report, tt.format); got != tt.want {
+			t.Errorf("ReportFilename(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+	report.Folder = ""
+	if got := ReportFilename(report, "json"); got != "shop-api.report.json" {
+		t.Errorf("without folder = %q", got)
+	}
+}
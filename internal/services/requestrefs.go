@@ -0,0 +1,163 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// responseRefRegex matches `name.response.body.<path>` and
+// `name.response.headers.<Header-Name>` inside {{...}}.
+var responseRefRegex = regexp.MustCompile(`^(\w+)\.response\.(body|headers)(?:\.(.+))?$`)
+
+// ResponseLookup returns the response of the request named name.
+type ResponseLookup func(name string) (models.HttpResponse, error)
+
+// NamedRequest locates a `# @name` request inside a project.
+type NamedRequest struct {
+	Request    models.ParsedHttpRequest
+	HistoryKey string
+}
+
+// requestCycleError reports a named request that references itself,
+// directly or through the other requests of chain.
+type requestCycleError struct {
+	chain []string
+}
+
+func (e *requestCycleError) Error() string {
+	return "request reference cycle: " + strings.Join(e.chain, " → ")
+}
+
+// RunNamedRequest executes a named request on demand and returns its result.
+type RunNamedRequest func(named NamedRequest) (models.RunResult, error)
+
+// FindNamedRequest looks for a request declared with `# @name name`, first
+// in the current file and then across .carmelia/requests/.
+func FindNamedRequest(projectPath string, current []models.ParsedHttpRequest, currentKey, name string) (NamedRequest, error) {
+	for _, req := range current {
+		if req.Name == name {
+			return NamedRequest{
+				Request:    req,
+				HistoryKey: RequestHistoryKey(currentKey, req, len(current)),
+			}, nil
+		}
+	}
+
+	if projectPath == "" {
+		return NamedRequest{}, fmt.Errorf("request %q not found", name)
+	}
+	all, err := CollectAllRequests(projectPath)
+	if err != nil {
+		return NamedRequest{}, err
+	}
+	perFile := map[string]int{}
+	for _, req := range all {
+		perFile[req.Path]++
+	}
+	for _, req := range all {
+		if req.Parsed.Name == name {
+			return NamedRequest{
+				Request:    req.Parsed,
+				HistoryKey: RequestHistoryKey(req.Path, req.Parsed, perFile[req.Path]),
+			}, nil
+		}
+	}
+	return NamedRequest{}, fmt.Errorf("request %q not found", name)
+}
+
+// NamedResponseLookup serves the latest successful history entry of a named
+// request, running the request through run when it has none. Responses are
+// cached for the lifetime of the lookup, so a request referenced several
+// times in one execution runs at most once. visiting lists the names
+// already being resolved up the chain, outermost first; they are rejected
+// with a *requestCycleError to stop reference cycles.
+func NamedResponseLookup(projectPath string, current []models.ParsedHttpRequest, currentKey string, visiting []string, run RunNamedRequest) ResponseLookup {
+	// A script that timed out may still be looking up a response
+	var mu sync.Mutex
+	cache := map[string]models.HttpResponse{}
+	cached := func(name string, resp models.HttpResponse) {
+		mu.Lock()
+		cache[name] = resp
+		mu.Unlock()
+	}
+
+	return func(name string) (models.HttpResponse, error) {
+		mu.Lock()
+		resp, ok := cache[name]
+		mu.Unlock()
+		if ok {
+			return resp, nil
+		}
+		for i, v := range visiting {
+			if v == name {
+				chain := append(append([]string{}, visiting[i:]...), name)
+				return models.HttpResponse{}, &requestCycleError{chain: chain}
+			}
+		}
+
+		named, err := FindNamedRequest(projectPath, current, currentKey, name)
+		if err != nil {
+			return models.HttpResponse{}, err
+		}
+
+		history, _ := LoadHistory(projectPath, named.HistoryKey)
+		for _, entry := range history {
+			if entry.Error == "" {
+				cached(name, entry.Response)
+				return entry.Response, nil
+			}
+		}
+
+		if run == nil {
+			return models.HttpResponse{}, fmt.Errorf("request %q has no response yet", name)
+		}
+		result, err := run(named)
+		if err != nil {
+			return models.HttpResponse{}, err
+		}
+		if result.Error != "" {
+			return models.HttpResponse{}, fmt.Errorf("request %q failed: %s", name, result.Error)
+		}
+		cached(name, result.Response)
+		return result.Response, nil
+	}
+}
+
+// resolveResponseRef resolves `name.response.body.<JSONPath>` and
+// `name.response.headers.<name>`. ok is false when expr is not a
+// response reference or the value cannot be found; err is set when the
+// response itself could not be looked up.
+func resolveResponseRef(expr string, lookup ResponseLookup) (string, bool, error) {
+	match := responseRefRegex.FindStringSubmatch(expr)
+	if match == nil || lookup == nil {
+		return "", false, nil
+	}
+
+	resp, err := lookup(match[1])
+	if err != nil {
+		return "", false, err
+	}
+
+	part, path := match[2], match[3]
+	if part == "headers" {
+		if path == "" || !resp.Headers.Has(path) {
+			return "", false, nil
+		}
+		return resp.Headers.Get(path), true, nil
+	}
+
+	if path == "" || path == "*" {
+		return resp.Body, true, nil
+	}
+	values, err := QueryJSON(resp.Body, path)
+	if err != nil || len(values) == 0 {
+		return "", false, nil
+	}
+	if len(values) == 1 {
+		return formatJSONValue(values[0]), true, nil
+	}
+	return formatJSONValue(values), true, nil
+}
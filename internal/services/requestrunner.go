@@ -0,0 +1,168 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"context"
+	"time"
+)
+
+// RequestRun is the context shared by a request and the named requests it
+// references: the project, the active env and the file being executed.
+// Data, when set, is the data file row of the current iteration.
+type RequestRun struct {
+	ProjectPath string
+	EnvName     string
+	File        []models.ParsedHttpRequest
+	FileKey     string
+	Data        map[string]string
+}
+
+// RunRequest resolves, executes and records one request of run.File:
+// scripts, captures and assertions included. historyKey groups its
+// history entries.
+func RunRequest(run RequestRun, parsed models.ParsedHttpRequest, historyKey string, sets map[string]string) models.RunResult {
+	return runRequest(run, parsed, historyKey, sets, nil)
+}
+
+// runRequest resolves, executes and records one request. Named requests
+// referenced through {{name.response...}} are served from history or run
+// first; visiting lists the names already on the chain.
+func runRequest(run RequestRun, parsed models.ParsedHttpRequest, hKey string, sets map[string]string, visiting []string) models.RunResult {
+	// Load env variables
+	env := models.EnvVariables{}
+	if run.EnvName != "" && run.ProjectPath != "" {
+		var err error
+		env, err = LoadEnv(run.ProjectPath, run.EnvName)
+		if err != nil {
+			env = models.EnvVariables{}
+		}
+	}
+
+	if parsed.Name != "" {
+		visiting = append(visiting[:len(visiting):len(visiting)], parsed.Name)
+	}
+
+	responses := NamedResponseLookup(run.ProjectPath, run.File, run.FileKey, visiting,
+		func(named NamedRequest) (models.RunResult, error) {
+			return runRequest(run, named.Request, named.HistoryKey, map[string]string{}, visiting), nil
+		})
+
+	// Load config for timeout/redirect settings
+	config, _ := LoadConfig(run.ProjectPath)
+
+	opts := ResolveOptions{
+		Env:         env,
+		Sets:        sets,
+		Data:        run.Data,
+		Runtime:     RuntimeValues(run.ProjectPath, run.EnvName),
+		Responses:   responses,
+		Dynamic:     DynamicValues{},
+		Secrets:     projectSecrets(run.ProjectPath),
+		ProjectPath: run.ProjectPath,
+	}
+
+	label := parsed.Name
+	if label == "" {
+		label = parsed.Method + " " + parsed.URL
+	}
+
+	// Variables set by scripts apply to this run and are kept in the
+	// runtime store for the following ones
+	scriptVars := map[string]string{}
+	scriptEnv := ScriptEnv{
+		Opts:        &opts,
+		Vars:        scriptVars,
+		ProjectPath: run.ProjectPath,
+		BaseDir:     ScriptDir(run.ProjectPath, hKey),
+		Timeout:     time.Duration(config.Runner.ScriptTimeout) * time.Millisecond,
+		FileAccess:  config.Runner.ScriptFileAccess,
+	}
+	defer func() {
+		if len(scriptVars) > 0 {
+			SetRuntimeValues(run.ProjectPath, run.EnvName, scriptVars, label)
+		}
+	}()
+
+	pre := scriptEnv
+	pre.Request = &parsed
+	scripts, err := RunScripts(parsed.Scripts, ScriptPre, pre)
+	if err != nil {
+		// Nothing was sent, so there is nothing to record in history
+		return models.RunResult{
+			Request: parsed,
+			Error:   err.Error(),
+			Scripts: scripts,
+		}
+	}
+
+	// Resolve variables
+	var masked maskedRequest
+	resolved, unresolved := resolveRequest(parsed, opts, nil, &masked)
+
+	switch config.Runner.OnUnresolved {
+	case "ignore":
+		unresolved = nil
+	case "error":
+		if len(unresolved) > 0 {
+			// Nothing was sent, so there is nothing to record in history
+			return models.RunResult{
+				Request:    resolved,
+				Error:      "unresolved variables: " + FormatUnresolved(unresolved),
+				Unresolved: unresolved,
+				Scripts:    scripts,
+			}
+		}
+	}
+
+	// Execute request
+	exec := ExecuteOptions{
+		Method:          resolved.Method,
+		URL:             resolved.URL,
+		Headers:         resolved.Headers,
+		Body:            resolved.Body,
+		Timeout:         config.Runner.Timeout,
+		FollowRedirects: config.Runner.FollowRedirects,
+	}
+	if !parsed.NoCookieJar && run.ProjectPath != "" {
+		exec.Jar = OpenCookieJar(run.ProjectPath, run.EnvName)
+	}
+	var resp models.HttpResponse
+	if exec.TLS, err = EnvTLSConfig(run.ProjectPath, run.EnvName); err == nil {
+		resp, err = ExecuteRequest(context.Background(), exec)
+	}
+
+	if err != nil {
+		result := models.RunResult{
+			Request:    resolved,
+			Error:      err.Error(),
+			Unresolved: unresolved,
+			Scripts:    scripts,
+		}
+		// Save to history even on error
+		SaveHistoryEntry(run.ProjectPath, hKey, config.Runner.MaxHistory, redactRunResult(run.ProjectPath, result, masked))
+		return result
+	}
+
+	result := models.RunResult{
+		Request:    resolved,
+		Response:   resp,
+		Unresolved: unresolved,
+	}
+	if len(parsed.Captures) > 0 {
+		result.Captures = RunCaptures(run.ProjectPath, run.EnvName, label, parsed.Captures, resp)
+	}
+	if len(parsed.Assertions) > 0 {
+		result.Assertions = RunAssertions(parsed.Assertions, resp)
+	}
+
+	post := scriptEnv
+	post.Request = &resolved
+	post.Response = &resp
+	postScripts, _ := RunScripts(parsed.Scripts, ScriptPost, post)
+	result.Scripts = append(scripts, postScripts...)
+
+	// Auto-save to history. This happens before returning so the next
+	// request of a run can read the response through {{name.response...}}
+	SaveHistoryEntry(run.ProjectPath, hKey, config.Runner.MaxHistory, redactRunResult(run.ProjectPath, result, masked))
+	return result
+}
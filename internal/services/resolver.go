@@ -1,95 +1,537 @@
 package services
 
 import (
-	"encoding/json"
 	"carmelia-desktop/internal/models"
+	"errors"
 	"os"
 	"regexp"
-	"strconv"
+	"strings"
 )
 
-var varRegex = regexp.MustCompile(`\{\{(\w+)\}\}`)
-var envVarRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+// placeholderRegex matches {{{raw}}}, {{var}} and ${VAR}.
+var placeholderRegex = regexp.MustCompile(`\{\{\{\s*([^{}]+?)\s*\}\}\}|\{\{\s*([^{}]+?)\s*\}\}|\$\{([^}]+)\}`)
 
+// ResolveOptions holds the variable sources for {{var}} lookups, in order
+// of precedence: Sets, then Script (values set by scripts during this run),
+// then Data (the row of the current data file iteration), then File
+// (`@name = value` declarations in the .http file), then Runtime
+// (values captured by earlier runs), then Env. ${VAR} always reads the
+// system environment.
+// Below them all, header values of ResolveRequest can read the resolved
+// request body as {{body}}.
+// {{name.response.body.$.path}} and {{name.response.headers.Name}} are
+// served by Responses, and built-ins such as {{$uuid}} are recorded in
+// Dynamic so each is evaluated once per execution. Secrets maps the values
+// of unlocked secrets to their names, so that what is inserted from them
+// can be masked. Pending names are set by scripts once the request runs:
+// resolving without running them leaves those placeholders as written
+// instead of reporting them.
 type ResolveOptions struct {
-	Env  models.EnvVariables   `json:"env"`
-	Sets map[string]string     `json:"sets"`
+	Env         models.EnvVariables `json:"env"`
+	Sets        map[string]string   `json:"sets"`
+	Script      map[string]string   `json:"script,omitempty"`
+	Data        map[string]string   `json:"data,omitempty"`
+	File        map[string]string   `json:"file,omitempty"`
+	Runtime     map[string]string   `json:"runtime,omitempty"`
+	Responses   ResponseLookup      `json:"-"`
+	Dynamic     DynamicValues       `json:"-"`
+	Secrets     map[string]string   `json:"-"`
+	Pending     map[string]bool     `json:"-"`
+	ProjectPath string              `json:"-"`
 }
 
-func ResolveVariables(text string, opts ResolveOptions) string {
-	result := text
+// Reasons reported in models.UnresolvedVariable.
+const (
+	UnresolvedUndefined = "undefined"
+	UnresolvedCycle     = "cycle"
+	UnresolvedSystem    = "system"
+	UnresolvedFilter    = "filter"
+)
+
+// maxResolveDepth bounds nested lookups as a safety net next to the cycle
+// check.
+const maxResolveDepth = 32
+
+// varSource is one named level of the {{var}} precedence chain.
+type varSource struct {
+	name   string
+	values map[string]string
+}
+
+// stackEntry is a variable being resolved and the source level its value
+// came from.
+type stackEntry struct {
+	name  string
+	level int
+}
+
+// resolver resolves placeholders recursively: a value may itself contain
+// {{var}} or ${VAR}. A value that references its own name reads the next
+// source down (so `@base_url = {{base_url}}/v2` extends the env value);
+// any other loop is reported as a cycle.
+type resolver struct {
+	opts       ResolveOptions
+	sources    []varSource
+	stack      []stackEntry
+	field      string
+	unresolved []models.UnresolvedVariable
+	reported   map[string]bool
+
+	// body, when set, is the value of {{body}}
+	body *string
+	// source is the source of the last value returned by lookup
+	source string
+	// trace, when non-nil, collects every top-level placeholder
+	trace *[]models.VariableTrace
+
+	// secret is set when a value read since the current placeholder
+	// started came from a secret
+	secret bool
+	// sensitive also masks secret-looking names and sensitive headers
+	sensitive bool
+	// secretBody is set when the value of {{body}} holds a secret
+	secretBody bool
+	// masked collects the text inserted for placeholders holding a secret
+	masked map[string]bool
+}
+
+func newResolver(opts ResolveOptions) *resolver {
+	return &resolver{
+		opts: opts,
+		sources: []varSource{
+			{name: "set", values: opts.Sets},
+			{name: "script", values: opts.Script},
+			{name: "data", values: opts.Data},
+			{name: "file", values: opts.File},
+			{name: "runtime", values: opts.Runtime},
+			{name: "env", values: opts.Env},
+		},
+		reported: map[string]bool{},
+		masked:   map[string]bool{},
+	}
+}
+
+// resolveText replaces every placeholder in text, encoding each value for
+// its position as tracked by ctx. Unresolved placeholders are kept as-is.
+func (r *resolver) resolveText(text string, ctx textContext) string {
+	resolved, _ := r.resolveMasked(text, ctx)
+	return resolved
+}
+
+// resolveMasked is resolveText that also returns the text with every value
+// derived from a secret masked, wherever and however it was inserted.
+func (r *resolver) resolveMasked(text string, ctx textContext) (string, string) {
+	var b, masked strings.Builder
+	last := 0
+	for _, m := range placeholderRegex.FindAllStringSubmatchIndex(text, -1) {
+		literal := text[last:m[0]]
+		b.WriteString(literal)
+		masked.WriteString(literal)
+		ctx.advance(literal)
+		match := text[m[0]:m[1]]
+		last = m[1]
+		topLevel := len(r.stack) == 0
+
+		var name, val string
+		var ok bool
+		r.source = ""
+		outer := r.secret
+		r.secret = false
+		switch {
+		case m[2] >= 0: // {{{raw}}}
+			name = text[m[2]:m[3]]
+			if val, ok = r.lookup(name); ok {
+				match = val
+			}
+		case m[4] >= 0:
+			name = text[m[4]:m[5]]
+			if val, ok = r.lookup(name); ok {
+				match = val
+				if !ctx.escapedBy(lastFilter(name)) {
+					match = ctx.encode(val)
+				}
+			}
+		default:
+			name = text[m[6]:m[7]]
+			if val = os.Getenv(name); val != "" {
+				ok = true
+				r.source = "system"
+				match = ctx.encode(val)
+			} else {
+				r.report(name, UnresolvedSystem, nil)
+			}
+		}
+		secret := ok && (r.secret || topLevel && r.sensitive && isSensitiveField(r.field))
+		r.secret = outer || secret
+		b.WriteString(match)
+		if secret {
+			masked.WriteString(maskedValue)
+			r.masked[match] = true
+		} else {
+			masked.WriteString(match)
+		}
+
+		if topLevel && r.trace != nil {
+			trace := models.VariableTrace{
+				Field:    r.field,
+				Start:    m[0],
+				End:      m[1],
+				Expr:     text[m[0]:m[1]],
+				Name:     name,
+				Value:    val,
+				Source:   r.source,
+				Resolved: ok,
+			}
+			if secret {
+				trace.Value = maskedValue
+				trace.Masked = true
+			}
+			*r.trace = append(*r.trace, trace)
+		}
+	}
+	b.WriteString(text[last:])
+	masked.WriteString(text[last:])
+	return b.String(), masked.String()
+}
+
+// lookup resolves one {{...}} expression.
+func (r *resolver) lookup(expr string) (string, bool) {
+	if pipes := splitPipes(expr); len(pipes) > 1 {
+		return r.pipe(pipes[0], pipes[1:])
+	}
+
+	start := 0
+	for _, entry := range r.stack {
+		if entry.name == expr && entry.level+1 > start {
+			start = entry.level + 1
+		}
+	}
+
+	for level := start; level < len(r.sources); level++ {
+		raw, ok := r.sources[level].values[expr]
+		if !ok {
+			continue
+		}
+		if len(r.stack) >= maxResolveDepth {
+			r.report(expr, UnresolvedCycle, r.chain(expr))
+			return "", false
+		}
+		r.stack = append(r.stack, stackEntry{name: expr, level: level})
+		val := r.resolveText(raw, rawContext{})
+		r.stack = r.stack[:len(r.stack)-1]
+		r.source = r.sources[level].name
+		if r.isSecret(expr, raw) || r.isSecret(expr, val) {
+			r.secret = true
+		}
+		return val, true
+	}
+
+	if start > 0 {
+		// Every source that defines expr is already on the stack
+		r.report(expr, UnresolvedCycle, r.chain(expr))
+		return "", false
+	}
+
+	// The body is resolved already, so it is not read for placeholders
+	if expr == "body" && r.body != nil {
+		r.source = "request"
+		r.secret = r.secret || r.secretBody
+		return *r.body, true
+	}
+
+	if responseRefRegex.MatchString(expr) {
+		val, ok, err := resolveResponseRef(expr, r.opts.Responses)
+		if ok {
+			r.source = "response"
+			return val, true
+		}
+		var cycle *requestCycleError
+		if errors.As(err, &cycle) {
+			r.report(expr, UnresolvedCycle, cycle.chain)
+			return "", false
+		}
+	} else if val, ok := r.lookupPath(expr); ok {
+		return val, true
+	}
+	if val, ok := evalDynamic(expr, r.opts); ok {
+		r.source = "dynamic"
+		return val, true
+	}
+
+	if r.opts.Pending[pathHead(expr)] {
+		return "", false
+	}
+	r.report(expr, UnresolvedUndefined, nil)
+	return "", false
+}
+
+// lookupPath resolves `name.key`, `name[0].key` and other JSONPath
+// suffixes into a variable holding JSON, such as a nested env value.
+func (r *resolver) lookupPath(expr string) (string, bool) {
+	i := strings.IndexAny(expr, ".[")
+	if i <= 0 || !r.defined(expr[:i]) {
+		return "", false
+	}
+	val, ok := r.lookup(expr[:i])
+	if !ok {
+		return "", false
+	}
+	values, err := QueryJSON(val, expr[i:])
+	if err != nil || len(values) == 0 {
+		return "", false
+	}
+	if len(values) == 1 {
+		return formatJSONValue(values[0]), true
+	}
+	return formatJSONValue(values), true
+}
+
+// pipe resolves `head | filter args... | ...`. head may be a quoted
+// literal; unquoted filter arguments naming a defined variable are replaced
+// by its value, e.g. `{{body | hmac_sha256 api_secret | hex}}` in a header.
+func (r *resolver) pipe(head string, calls []string) (string, bool) {
+	var val string
+	if len(head) >= 2 && (head[0] == '"' || head[0] == '\'') && head[len(head)-1] == head[0] {
+		val = head[1 : len(head)-1]
+		r.source = "literal"
+	} else {
+		var ok bool
+		if val, ok = r.lookup(head); !ok {
+			return "", false
+		}
+	}
+	source := r.source
+	defer func() { r.source = source }()
 
-	// 1. Resolve {{var}} — sets override env
-	result = varRegex.ReplaceAllStringFunc(result, func(match string) string {
-		varName := varRegex.FindStringSubmatch(match)[1]
-		if val, ok := opts.Sets[varName]; ok {
-			return val
+	for _, call := range calls {
+		name, args := splitFilterArgs(call)
+		fn, ok := lookupFilter(name)
+		if !ok {
+			r.reportFilter(name, "unknown filter")
+			return "", false
 		}
-		if val, ok := opts.Env[varName]; ok {
-			return val
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = arg.text
+			if !arg.quoted && r.defined(arg.text) {
+				// A secret argument, such as a signing key, does not
+				// make the result a secret
+				secret := r.secret
+				if v, ok := r.lookup(arg.text); ok {
+					values[i] = v
+				}
+				r.secret = secret
+			}
 		}
-		return match
-	})
+		out, err := fn(val, values)
+		if err != nil {
+			r.reportFilter(name, err.Error())
+			return "", false
+		}
+		val = out
+	}
+	return val, true
+}
 
-	// 2. Resolve ${ENV_VAR} — system environment
-	result = envVarRegex.ReplaceAllStringFunc(result, func(match string) string {
-		varName := envVarRegex.FindStringSubmatch(match)[1]
-		if val := os.Getenv(varName); val != "" {
-			return val
+// isSecret reports whether the value of variable name came from a secret,
+// or, for previews, whether name looks like one.
+func (r *resolver) isSecret(name, value string) bool {
+	if _, ok := r.opts.Secrets[value]; ok && value != "" {
+		return true
+	}
+	return r.sensitive && secretNameRegex.MatchString(name)
+}
+
+// defined reports whether name is set in any named source.
+func (r *resolver) defined(name string) bool {
+	for _, src := range r.sources {
+		if _, ok := src.values[name]; ok {
+			return true
 		}
-		return match
-	})
+	}
+	return name == "body" && r.body != nil
+}
+
+// lastFilter returns the name of the last filter of a `value | filter`
+// expression, or "" when it has none.
+func lastFilter(expr string) string {
+	pipes := splitPipes(expr)
+	if len(pipes) < 2 {
+		return ""
+	}
+	name, _ := splitFilterArgs(pipes[len(pipes)-1])
+	return name
+}
+
+// chain returns the names on the stack from the first occurrence of name,
+// closed with name itself, e.g. [a b a].
+func (r *resolver) chain(name string) []string {
+	var out []string
+	for _, entry := range r.stack {
+		if entry.name == name || len(out) > 0 {
+			out = append(out, entry.name)
+		}
+	}
+	return append(out, name)
+}
+
+func (r *resolver) report(name, reason string, chain []string) {
+	r.add(models.UnresolvedVariable{Name: name, Reason: reason, Chain: chain})
+}
+
+func (r *resolver) reportFilter(name, detail string) {
+	r.add(models.UnresolvedVariable{Name: name, Reason: UnresolvedFilter, Detail: detail})
+}
+
+func (r *resolver) add(u models.UnresolvedVariable) {
+	u.Field = r.field
+	key := u.Reason + "\x00" + u.Name + "\x00" + u.Field
+	if r.reported[key] {
+		return
+	}
+	r.reported[key] = true
+	r.unresolved = append(r.unresolved, u)
+}
 
-	return result
+// ResolveVariables resolves every placeholder in text, inserting values
+// unencoded. Placeholders that cannot be resolved are left as written.
+func ResolveVariables(text string, opts ResolveOptions) string {
+	return newResolver(opts).resolveText(text, rawContext{})
+}
+
+// ResolveRequest resolves the URL, headers and body of a request. Values
+// are percent-encoded in URL path and query positions and escaped inside
+// JSON string literals; header values are inserted as-is. It also
+// returns the placeholders that stayed unresolved (undefined, unset ${VAR}
+// or part of a reference cycle), each tagged with the field it was found in.
+func ResolveRequest(req models.ParsedHttpRequest, opts ResolveOptions) (models.ParsedHttpRequest, []models.UnresolvedVariable) {
+	return resolveRequest(req, opts, nil, nil)
+}
+
+// maskedRequest receives a resolved request with every value derived from
+// a secret masked, and the text those values were inserted as (encoded,
+// filtered). sensitive also masks the values of secret-looking names and
+// sensitive headers, as previews do.
+type maskedRequest struct {
+	sensitive bool
+	request   models.ParsedHttpRequest
+	values    []string
 }
 
-func ResolveRequest(req models.ParsedHttpRequest, opts ResolveOptions) models.ParsedHttpRequest {
+func resolveRequest(req models.ParsedHttpRequest, opts ResolveOptions, trace *[]models.VariableTrace, masked *maskedRequest) (models.ParsedHttpRequest, []models.UnresolvedVariable) {
+	if opts.Dynamic == nil {
+		opts.Dynamic = DynamicValues{}
+	}
+	opts.File = mergeFileVariables(opts.File, req.FileVariables)
+
+	r := newResolver(opts)
+	r.trace = trace
+	r.sensitive = masked != nil && masked.sensitive
+
+	r.field = "url"
+	url, maskedURL := r.resolveMasked(req.URL, &urlContext{})
 	resolved := models.ParsedHttpRequest{
+		Name:     req.Name,
+		Index:    req.Index,
 		Method:   req.Method,
-		URL:      ResolveVariables(req.URL, opts),
-		Headers:  map[string]string{},
+		URL:      url,
+		Headers:  make(models.Headers, 0, len(req.Headers)),
 		Comments: req.Comments,
 	}
+	maskedReq := resolved
+	maskedReq.URL = maskedURL
+	maskedReq.Headers = make(models.Headers, 0, len(req.Headers))
 
-	for k, v := range req.Headers {
-		resolved.Headers[k] = ResolveVariables(v, opts)
-	}
-
+	// The body is resolved before the headers so they can sign it with
+	// {{body | ...}}; its placeholders are still traced after theirs
+	var bodyTrace []models.VariableTrace
 	if req.Body != "" {
-		resolved.Body = ResolveVariables(req.Body, opts)
+		r.field = "body"
+		if trace != nil {
+			r.trace = &bodyTrace
+		}
+		contentType := req.Headers.Get("Content-Type")
+		if strings.Contains(contentType, "{{") || strings.Contains(contentType, "${") {
+			contentType = newResolver(opts).resolveText(contentType, rawContext{})
+		}
+		resolved.Body, maskedReq.Body = r.resolveMasked(req.Body, bodyContext(contentType, req.Body))
 
-		// Apply --set overrides to JSON body fields
+		// Apply --set overrides to body fields
 		if len(opts.Sets) > 0 {
-			trimmed := []byte(resolved.Body)
-			var parsed map[string]interface{}
-			if err := json.Unmarshal(trimmed, &parsed); err == nil {
-				for key, value := range opts.Sets {
-					if _, exists := parsed[key]; exists {
-						parsed[key] = parseValue(value)
-					}
-				}
-				if out, err := json.MarshalIndent(parsed, "", "  "); err == nil {
-					resolved.Body = string(out)
+			resolved.Body = applyBodyOverrides(resolved.Body, contentType, opts.Sets)
+			maskedSets := make(map[string]string, len(opts.Sets))
+			for name, value := range opts.Sets {
+				if r.isSecret(name, value) {
+					value = maskedValue
 				}
+				maskedSets[name] = value
 			}
+			maskedReq.Body = applyBodyOverrides(maskedReq.Body, contentType, maskedSets)
 		}
+		r.trace = trace
 	}
+	r.body = &resolved.Body
+	r.secretBody = maskedReq.Body != resolved.Body
 
-	return resolved
-}
+	for _, h := range req.Headers {
+		r.field = "header " + h.Name
+		value, hidden := r.resolveMasked(h.Value, rawContext{})
+		resolved.Headers.Add(h.Name, value)
+		maskedReq.Headers.Add(h.Name, hidden)
+	}
+	if trace != nil {
+		*trace = append(*trace, bodyTrace...)
+	}
 
-func parseValue(value string) interface{} {
-	if value == "true" {
-		return true
+	if len(opts.Dynamic) > 0 {
+		resolved.DynamicValues = opts.Dynamic
+		maskedReq.DynamicValues = opts.Dynamic
 	}
-	if value == "false" {
-		return false
+
+	if masked != nil {
+		masked.request = maskedReq
+		masked.values = sortedKeys(r.masked)
+	}
+	return resolved, r.unresolved
+}
+
+// FormatUnresolved renders an unresolved-variable report as one line, e.g.
+// "{{base_url}} (undefined, url), {{a}} (cycle a → b → a, body)".
+func FormatUnresolved(unresolved []models.UnresolvedVariable) string {
+	parts := make([]string, 0, len(unresolved))
+	for _, u := range unresolved {
+		name := "{{" + u.Name + "}}"
+		if u.Reason == UnresolvedSystem {
+			name = "${" + u.Name + "}"
+		}
+		reason := u.Reason
+		if u.Reason == UnresolvedFilter {
+			name = "| " + u.Name
+		}
+		if u.Detail != "" {
+			reason += " " + u.Detail
+		}
+		if len(u.Chain) > 0 {
+			reason += " " + strings.Join(u.Chain, " → ")
+		}
+		if u.Field != "" {
+			reason += ", " + u.Field
+		}
+		parts = append(parts, name+" ("+reason+")")
 	}
-	if value == "null" {
-		return nil
+	return strings.Join(parts, ", ")
+}
+
+// mergeFileVariables layers the file's `@name = value` declarations over
+// base. A later declaration of the same name replaces the earlier one;
+// values stay raw and are resolved on lookup.
+func mergeFileVariables(base map[string]string, vars []models.FileVariable) map[string]string {
+	file := make(map[string]string, len(base)+len(vars))
+	for k, v := range base {
+		file[k] = v
 	}
-	if num, err := strconv.ParseFloat(value, 64); err == nil {
-		return num
+	for _, v := range vars {
+		file[v.Name] = v.Value
 	}
-	return value
+	return file
 }
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestResolveVariables(t *testing.T) {
+	tests := []struct {
+		name string
+		opts ResolveOptions
+		text string
+		want string
+	}{
+		{
+			name: "env",
+			opts: ResolveOptions{Env: models.EnvVariables{"host": "example.com"}},
+			text: "https://{{host}}/", want: "https://example.com/",
+		},
+		{
+			name: "sets over file over env",
+			opts: ResolveOptions{
+				Env:  models.EnvVariables{"a": "env", "b": "env", "c": "env"},
+				File: map[string]string{"a": "file", "b": "file"},
+				Sets: map[string]string{"a": "set"},
+			},
+			text: "{{a}} {{b}} {{c}}", want: "set file env",
+		},
+		{
+			name: "runtime between file and env",
+			opts: ResolveOptions{
+				Env:     models.EnvVariables{"a": "env", "b": "env"},
+				Runtime: map[string]string{"a": "runtime", "b": "runtime"},
+				File:    map[string]string{"a": "file"},
+			},
+			text: "{{a}} {{b}}", want: "file runtime",
+		},
+		{
+			name: "script between sets and file",
+			opts: ResolveOptions{
+				File:   map[string]string{"a": "file", "b": "file"},
+				Script: map[string]string{"a": "script", "b": "script"},
+				Sets:   map[string]string{"a": "set"},
+			},
+			text: "{{a}} {{b}}", want: "set script",
+		},
+		{
+			name: "data between script and file",
+			opts: ResolveOptions{
+				File:   map[string]string{"a": "file", "b": "file"},
+				Data:   map[string]string{"a": "data", "b": "data"},
+				Script: map[string]string{"a": "script"},
+			},
+			text: "{{a}} {{b}}", want: "script data",
+		},
+		{
+			name: "nested values",
+			opts: ResolveOptions{Env: models.EnvVariables{"base": "https://{{host}}", "host": "example.com"}},
+			text: "{{ base }}/users", want: "https://example.com/users",
+		},
+		{
+			name: "self reference reads the next source down",
+			opts: ResolveOptions{
+				File: map[string]string{"base_url": "{{base_url}}/v2"},
+				Env:  models.EnvVariables{"base_url": "https://example.com"},
+			},
+			text: "{{base_url}}", want: "https://example.com/v2",
+		},
+		{
+			name: "undefined stays as written",
+			text: "{{missing}} and {{ other }}", want: "{{missing}} and {{ other }}",
+		},
+		{
+			name: "cycle stays as written",
+			opts: ResolveOptions{Env: models.EnvVariables{"a": "{{b}}", "b": "{{a}}"}},
+			text: "x{{a}}", want: "x{{a}}",
+		},
+		{
+			name: "JSON path into a value",
+			opts: ResolveOptions{Env: models.EnvVariables{"user": `{"name": "Ada", "roles": ["admin", "dev"]}`}},
+			text: "{{user.name}} {{user.roles[1]}}", want: "Ada dev",
+		},
+		{
+			name: "filters",
+			opts: ResolveOptions{Env: models.EnvVariables{"name": " Ada ", "n": "41"}},
+			text: "{{name | trim | upper}} {{n | add 1}} {{'a b' | urlencode}}", want: "ADA 42 a+b",
+		},
+		{
+			name: "filter arguments read variables unless quoted",
+			opts: ResolveOptions{Env: models.EnvVariables{"n": "10", "step": "5"}},
+			text: "{{n | add step}} {{n | mul '2'}}", want: "15 20",
+		},
+		{
+			name: "unknown filter stays as written",
+			opts: ResolveOptions{Env: models.EnvVariables{"n": "1"}},
+			text: "{{n | nope}}", want: "{{n | nope}}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ResolveVariables(tt.text, tt.opts); got != tt.want {
+				t.Errorf("ResolveVariables(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveRequestEncoding(t *testing.T) {
+	opts := ResolveOptions{Env: models.EnvVariables{
+		"base": "https://example.com/api",
+		"id":   "a/b c",
+		"q":    "x&y=z",
+		"text": `say "hi"`,
+		"n":    "3",
+	}}
+	tests := []struct {
+		name        string
+		req         models.ParsedHttpRequest
+		wantURL     string
+		wantBody    string
+		wantHeaders models.Headers
+	}{
+		{
+			name:    "URL positions",
+			req:     models.ParsedHttpRequest{URL: "{{base}}/items/{{id}}?q={{q}}#{{id}}"},
+			wantURL: "https://example.com/api/items/a%2Fb%20c?q=x%26y%3Dz#a%2Fb%20c",
+		},
+		{
+			name:    "raw placeholder",
+			req:     models.ParsedHttpRequest{URL: "{{base}}/{{{id}}}"},
+			wantURL: "https://example.com/api/a/b c",
+		},
+		{
+			name:    "urlencode is not applied twice",
+			req:     models.ParsedHttpRequest{URL: "{{base}}?q={{q | urlencode}}"},
+			wantURL: "https://example.com/api?q=x%26y%3Dz",
+		},
+		{
+			name: "JSON strings are escaped, other values are not",
+			req: models.ParsedHttpRequest{
+				Headers: models.Headers{{Name: "Content-Type", Value: "application/json"}},
+				Body:    `{"text": "{{text}}", "n": {{n}}}`,
+			},
+			wantBody:    `{"text": "say \"hi\"", "n": 3}`,
+			wantHeaders: models.Headers{{Name: "Content-Type", Value: "application/json"}},
+		},
+		{
+			name:     "jsonescape is not applied twice",
+			req:      models.ParsedHttpRequest{Body: `{"text": "{{text | jsonescape}}"}`},
+			wantBody: `{"text": "say \"hi\""}`,
+		},
+		{
+			name: "form bodies are query-escaped",
+			req: models.ParsedHttpRequest{
+				Headers: models.Headers{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+				Body:    "q={{q}}&r={{id}}",
+			},
+			wantBody:    "q=x%26y%3Dz&r=a%2Fb+c",
+			wantHeaders: models.Headers{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+		},
+		{
+			name: "urlencode in a form body is not applied twice",
+			req: models.ParsedHttpRequest{
+				Headers: models.Headers{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+				Body:    "q={{q | urlencode}}",
+			},
+			wantBody:    "q=x%26y%3Dz",
+			wantHeaders: models.Headers{{Name: "Content-Type", Value: "application/x-www-form-urlencoded"}},
+		},
+		{
+			name:        "headers are inserted as-is",
+			req:         models.ParsedHttpRequest{Headers: models.Headers{{Name: "X-Text", Value: "{{text}} {{id}}"}}},
+			wantHeaders: models.Headers{{Name: "X-Text", Value: `say "hi" a/b c`}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unresolved := ResolveRequest(tt.req, opts)
+			if len(unresolved) > 0 {
+				t.Fatalf("unresolved: %s", FormatUnresolved(unresolved))
+			}
+			if got.URL != tt.wantURL {
+				t.Errorf("URL = %q, want %q", got.URL, tt.wantURL)
+			}
+			if got.Body != tt.wantBody {
+				t.Errorf("body = %q, want %q", got.Body, tt.wantBody)
+			}
+			if len(got.Headers) > 0 || len(tt.wantHeaders) > 0 {
+				if !reflect.DeepEqual(got.Headers, tt.wantHeaders) {
+					t.Errorf("headers = %v, want %v", got.Headers, tt.wantHeaders)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveRequestBodyVariable(t *testing.T) {
+	req := models.ParsedHttpRequest{
+		URL: "https://example.com/{{body}}",
+		Headers: models.Headers{
+			{Name: "X-Digest", Value: "{{body | sha256 | hex}}"},
+			{Name: "X-Signature", Value: "{{body | hmac_sha256 secret | hex}}"},
+		},
+		Body: `{"n": {{n}}}`,
+	}
+	opts := ResolveOptions{Env: models.EnvVariables{"n": "1", "secret": "k"}}
+
+	var trace []models.VariableTrace
+	got, unresolved := resolveRequest(req, opts, &trace, nil)
+
+	body := `{"n": 1}`
+	digest := sha256.Sum256([]byte(body))
+	mac := hmac.New(sha256.New, []byte("k"))
+	mac.Write([]byte(body))
+	want := models.Headers{
+		{Name: "X-Digest", Value: hex.EncodeToString(digest[:])},
+		{Name: "X-Signature", Value: hex.EncodeToString(mac.Sum(nil))},
+	}
+	if !reflect.DeepEqual(got.Headers, want) {
+		t.Errorf("headers = %v, want %v", got.Headers, want)
+	}
+
+	// The URL is resolved before the body exists
+	wantUnresolved := []models.UnresolvedVariable{{Name: "body", Reason: UnresolvedUndefined, Field: "url"}}
+	if !reflect.DeepEqual(unresolved, wantUnresolved) {
+		t.Errorf("unresolved = %+v, want %+v", unresolved, wantUnresolved)
+	}
+
+	var fields, sources []string
+	for _, tr := range trace {
+		fields = append(fields, tr.Field)
+		sources = append(sources, tr.Source)
+	}
+	if want := []string{"url", "header X-Digest", "header X-Signature", "body"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("trace fields = %v, want %v", fields, want)
+	}
+	if want := []string{"", "request", "request", "env"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("trace sources = %v, want %v", sources, want)
+	}
+
+	// A variable named body takes precedence over the request body
+	opts.Env["body"] = "env"
+	got, _ = ResolveRequest(models.ParsedHttpRequest{Headers: models.Headers{{Name: "X-Body", Value: "{{body}}"}}, Body: "payload"}, opts)
+	if got.Headers[0].Value != "env" {
+		t.Errorf("{{body}} = %q, want the env value", got.Headers[0].Value)
+	}
+}
+
+func TestResolveRequestUnresolved(t *testing.T) {
+	req := models.ParsedHttpRequest{
+		URL:  "{{base_url}}/{{a}}",
+		Body: "{{n | nope}} {{base_url}} ${CARMELIA_TEST_UNSET}",
+	}
+	opts := ResolveOptions{Env: models.EnvVariables{"a": "{{b}}", "b": "{{a}}", "n": "1"}}
+	_, unresolved := ResolveRequest(req, opts)
+
+	want := []models.UnresolvedVariable{
+		{Name: "base_url", Reason: UnresolvedUndefined, Field: "url"},
+		{Name: "a", Reason: UnresolvedCycle, Chain: []string{"a", "b", "a"}, Field: "url"},
+		{Name: "nope", Reason: UnresolvedFilter, Detail: "unknown filter", Field: "body"},
+		{Name: "base_url", Reason: UnresolvedUndefined, Field: "body"},
+		{Name: "CARMELIA_TEST_UNSET", Reason: UnresolvedSystem, Field: "body"},
+	}
+	if !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("unresolved = %+v, want %+v", unresolved, want)
+	}
+
+	wantText := "{{base_url}} (undefined, url), {{a}} (cycle a → b → a, url), " +
+		"| nope (filter unknown filter, body), " +
+		"{{base_url}} (undefined, body), ${CARMELIA_TEST_UNSET} (system, body)"
+	if got := FormatUnresolved(unresolved); got != wantText {
+		t.Errorf("FormatUnresolved = %q, want %q", got, wantText)
+	}
+}
+
+func TestResolveRequestPending(t *testing.T) {
+	req := models.ParsedHttpRequest{
+		URL:  "{{base}}/orders?ts={{ts}}&user={{user.id}}",
+		Body: "{{missing}}",
+	}
+	opts := ResolveOptions{Env: models.EnvVariables{"base": "http://x"}, Pending: map[string]bool{"ts": true, "user": true}}
+	resolved, unresolved := ResolveRequest(req, opts)
+	if want := "http://x/orders?ts={{ts}}&user={{user.id}}"; resolved.URL != want {
+		t.Errorf("url = %q, want %q", resolved.URL, want)
+	}
+	want := []models.UnresolvedVariable{{Name: "missing", Reason: UnresolvedUndefined, Field: "body"}}
+	if !reflect.DeepEqual(unresolved, want) {
+		t.Errorf("unresolved = %+v, want %+v", unresolved, want)
+	}
+}
+
+func TestResolveRequestCycles(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		env      models.EnvVariables
+		visiting []string
+		want     []string
+	}{
+		{name: "variable referencing itself", url: "{{a}}", env: models.EnvVariables{"a": "{{a}}"}, want: []string{"a", "a"}},
+		{name: "request referencing itself", url: "{{login.response.body.$.token}}", visiting: []string{"login"}, want: []string{"login", "login"}},
+		{name: "requests referencing each other", url: "{{a.response.headers.Location}}", visiting: []string{"a", "b"}, want: []string{"a", "b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := models.ParsedHttpRequest{URL: tt.url}
+			opts := ResolveOptions{Env: tt.env, Responses: NamedResponseLookup("", nil, "", tt.visiting, nil)}
+			resolved, unresolved := ResolveRequest(req, opts)
+			if resolved.URL != tt.url {
+				t.Errorf("url = %q, want it unchanged", resolved.URL)
+			}
+			if len(unresolved) != 1 || unresolved[0].Reason != UnresolvedCycle || !reflect.DeepEqual(unresolved[0].Chain, tt.want) {
+				t.Errorf("unresolved = %+v, want a cycle %v", unresolved, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastFilter(t *testing.T) {
+	tests := []struct{ expr, want string }{
+		{"name", ""},
+		{"name | urlencode", "urlencode"},
+		{"body | hmac_sha256 key | hex", "hex"},
+		{"'a | b'", ""},
+		{"'a | b' | jsonescape", "jsonescape"},
+	}
+	for _, tt := range tests {
+		if got := lastFilter(tt.expr); got != tt.want {
+			t.Errorf("lastFilter(%q) = %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
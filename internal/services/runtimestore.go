@@ -0,0 +1,140 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Runtime values live in .carmelia/runtime/<env>.json, next to but apart
+// from the committed env files. The directory ignores itself through its
+// own .gitignore.
+const runtimeDir = ".carmelia/runtime"
+
+// noEnvRuntime holds the runtime values used when no env is selected. Env
+// names starting with `_` are bases that cannot be selected, so it cannot
+// clash with a real env.
+const noEnvRuntime = "_none"
+
+var runtimeMu sync.Mutex
+
+func runtimePath(projectPath, envName string) string {
+	if envName == "" {
+		envName = noEnvRuntime
+	}
+	return filepath.Join(projectPath, runtimeDir, envName+".json")
+}
+
+// LoadRuntime returns the runtime values of an env.
+func LoadRuntime(projectPath, envName string) (models.RuntimeVariables, error) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	return readRuntime(projectPath, envName)
+}
+
+// RuntimeValues returns the runtime values of an env as variables, empty
+// when the store cannot be read.
+func RuntimeValues(projectPath, envName string) map[string]string {
+	vars, err := LoadRuntime(projectPath, envName)
+	if err != nil {
+		return map[string]string{}
+	}
+	values := make(map[string]string, len(vars))
+	for name, v := range vars {
+		values[name] = v.Value
+	}
+	return values
+}
+
+// SetRuntimeValues stores values in an env's runtime store, recording the
+// request that set them.
+func SetRuntimeValues(projectPath, envName string, values map[string]string, request string) error {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	vars, err := readRuntime(projectPath, envName)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	for name, value := range values {
+		vars[name] = models.RuntimeValue{Value: value, UpdatedAt: now, Request: request}
+	}
+	return writeRuntime(projectPath, envName, vars)
+}
+
+// DeleteRuntimeValue removes one value from an env's runtime store.
+func DeleteRuntimeValue(projectPath, envName, name string) error {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	vars, err := readRuntime(projectPath, envName)
+	if err != nil {
+		return err
+	}
+	if _, ok := vars[name]; !ok {
+		return nil
+	}
+	delete(vars, name)
+	return writeRuntime(projectPath, envName, vars)
+}
+
+// ClearRuntime removes every runtime value of an env.
+func ClearRuntime(projectPath, envName string) error {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	if err := os.Remove(runtimePath(projectPath, envName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear runtime values: %w", err)
+	}
+	return nil
+}
+
+func renameRuntime(projectPath, oldName, newName string) error {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+
+	if err := os.Rename(runtimePath(projectPath, oldName), runtimePath(projectPath, newName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func readRuntime(projectPath, envName string) (models.RuntimeVariables, error) {
+	data, err := os.ReadFile(runtimePath(projectPath, envName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return models.RuntimeVariables{}, nil
+		}
+		return nil, fmt.Errorf("failed to read runtime values: %w", err)
+	}
+
+	vars := models.RuntimeVariables{}
+	if err := json.Unmarshal(data, &vars); err != nil {
+		return nil, fmt.Errorf("failed to parse runtime values: %w", err)
+	}
+	return vars, nil
+}
+
+func writeRuntime(projectPath, envName string, vars models.RuntimeVariables) error {
+	dir := filepath.Join(projectPath, runtimeDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create runtime dir: %w", err)
+	}
+	ignore := filepath.Join(dir, ".gitignore")
+	if _, err := os.Stat(ignore); os.IsNotExist(err) {
+		if err := os.WriteFile(ignore, []byte("*\n"), 0o644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", ignore, err)
+		}
+	}
+
+	data, err := json.MarshalIndent(vars, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal runtime values: %w", err)
+	}
+	return os.WriteFile(runtimePath(projectPath, envName), data, 0o644)
+}
@@ -0,0 +1,506 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// Script phases.
+const (
+	ScriptPre  = "pre"
+	ScriptPost = "post"
+)
+
+// maxScriptLogs bounds the console output kept per script.
+const maxScriptLogs = 100
+
+// ScriptEnv is what scripts can see and change. Scripts run in a plain
+// JavaScript runtime: there is no require, filesystem or network access,
+// except reading project files through `files.read` when FileAccess is set.
+type ScriptEnv struct {
+	// Request is the request template for pre scripts, which may change
+	// it, and the resolved request for post scripts.
+	Request *models.ParsedHttpRequest
+	// Response is only set for post scripts.
+	Response *models.HttpResponse
+	// Opts are the variable sources. Values written with vars.set go to
+	// Opts.Script, so they apply to the rest of the run, and to Vars.
+	Opts *ResolveOptions
+	Vars map[string]string
+
+	ProjectPath string
+	// BaseDir resolves script files.
+	BaseDir    string
+	Timeout    time.Duration
+	FileAccess bool
+}
+
+// ScriptDir returns the folder script files are relative to: the folder
+// of the request file when historyKey is its path under
+// .carmelia/requests/, otherwise that root.
+func ScriptDir(projectPath, historyKey string) string {
+	root := filepath.Join(projectPath, ".carmelia", "requests")
+	path := historyKey
+	if i := strings.LastIndex(path, "#"); i >= 0 {
+		path = path[:i]
+	}
+	if !strings.HasSuffix(path, ".http") || strings.Contains(path, "\n") {
+		return root
+	}
+	return filepath.Join(root, filepath.Dir(path))
+}
+
+// RunScripts runs the scripts of one phase in order. It stops at the first
+// script that fails; the error is also returned so a failing pre script
+// can keep the request from being sent.
+func RunScripts(scripts []models.Script, phase string, env ScriptEnv) ([]models.ScriptResult, error) {
+	var results []models.ScriptResult
+	for _, script := range scripts {
+		if script.Phase != phase {
+			continue
+		}
+		result := models.ScriptResult{Phase: phase, Source: "inline"}
+		if script.File != "" {
+			result.Source = script.File
+		}
+		code, err := readScript(script, env.BaseDir)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to read script: %v", err)
+			return append(results, result), errors.New(result.Error)
+		}
+
+		if err := runScript(code, result.Source, env, &result.Logs); err != nil {
+			result.Error = err.Error()
+			return append(results, result), fmt.Errorf("%s script %s: %w", phase, result.Source, err)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// readScript returns the code of a script, reading its file relative to
+// baseDir.
+func readScript(script models.Script, baseDir string) (string, error) {
+	if script.File == "" {
+		return script.Code, nil
+	}
+	path := script.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	data, err := os.ReadFile(path)
+	return string(data), err
+}
+
+// scriptSetRegex matches vars.set calls with a literal variable name.
+var scriptSetRegex = regexp.MustCompile(`\bvars\.set\(\s*["'\x60]([^"'\x60]+)["'\x60]`)
+
+// ScriptSetNames returns the variables scripts set with a literal
+// `vars.set("name", ...)`, so checks made without running them do not
+// report those as undefined. Script files that cannot be read are skipped.
+func ScriptSetNames(scripts []models.Script, baseDir string) map[string]bool {
+	names := map[string]bool{}
+	for _, script := range scripts {
+		code, err := readScript(script, baseDir)
+		if err != nil {
+			continue
+		}
+		for _, m := range scriptSetRegex.FindAllStringSubmatch(code, -1) {
+			names[m[1]] = true
+		}
+	}
+	return names
+}
+
+// runScript runs code with a copy of what it can change, written back to
+// env when it ends in time. A script that times out is abandoned even
+// while a Go callback blocks, such as vars.get running a referenced
+// request, so it cannot change the run after it has been reported.
+func runScript(code, name string, env ScriptEnv, logs *[]string) error {
+	vm := goja.New()
+	vm.SetMaxCallStackSize(1024)
+
+	local := env
+	opts := *env.Opts
+	opts.Script = maps.Clone(env.Opts.Script)
+	opts.Dynamic = maps.Clone(env.Opts.Dynamic)
+	local.Opts = &opts
+	local.Vars = map[string]string{}
+	if env.Request != nil {
+		req := *env.Request
+		local.Request = &req
+	}
+	s := &scriptAPI{vm: vm, env: local}
+	s.install()
+
+	done := make(chan error, 1)
+	go func() { done <- s.run(name, code) }()
+
+	var timeout <-chan time.Time
+	if env.Timeout > 0 {
+		timer := time.NewTimer(env.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err := <-done:
+		*logs = append(*logs, s.logs...)
+		env.Opts.Script = opts.Script
+		env.Opts.Dynamic = opts.Dynamic
+		if env.Vars != nil {
+			maps.Copy(env.Vars, local.Vars)
+		}
+		if err == nil && env.Request != nil && env.Response == nil {
+			*env.Request = *local.Request
+		}
+		return err
+	case <-timeout:
+		vm.Interrupt(nil)
+		s.mu.Lock()
+		*logs = append(*logs, s.logs...)
+		s.mu.Unlock()
+		return fmt.Errorf("timed out after %s", env.Timeout)
+	}
+}
+
+// run runs the script in s.vm.
+func (s *scriptAPI) run(name, code string) (err error) {
+	// A panic that escapes the runtime fails the script, not the app
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	vm := s.vm
+	if _, err := vm.RunScript(name, code); err != nil {
+		var exception *goja.Exception
+		if errors.As(err, &exception) {
+			return scriptError(exception)
+		}
+		return err
+	}
+	if s.env.Request != nil && s.env.Response == nil {
+		s.readRequest()
+	}
+	return nil
+}
+
+// scriptError reports an uncaught exception with the script line it was
+// thrown from, leaving out Go frames.
+func scriptError(exception *goja.Exception) error {
+	msg := exception.Value().String()
+	for _, frame := range exception.Stack() {
+		if frame.SrcName() != "<native>" {
+			return fmt.Errorf("%s (line %d)", msg, frame.Position().Line)
+		}
+	}
+	return errors.New(msg)
+}
+
+// scriptAPI builds the globals of one script run. mu guards logs, which
+// are read when the script times out.
+type scriptAPI struct {
+	vm      *goja.Runtime
+	env     ScriptEnv
+	mu      sync.Mutex
+	logs    []string
+	request *goja.Object
+	headers models.Headers
+}
+
+func (s *scriptAPI) install() {
+	vm := s.vm
+
+	console := vm.NewObject()
+	for _, level := range []string{"log", "info", "warn", "error", "debug"} {
+		prefix := ""
+		if level == "warn" || level == "error" {
+			prefix = level + ": "
+		}
+		console.Set(level, func(call goja.FunctionCall) goja.Value {
+			parts := make([]string, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				parts[i] = s.display(arg)
+			}
+			s.mu.Lock()
+			if len(s.logs) < maxScriptLogs {
+				s.logs = append(s.logs, prefix+strings.Join(parts, " "))
+			}
+			s.mu.Unlock()
+			return goja.Undefined()
+		})
+	}
+	vm.Set("console", console)
+
+	if s.env.Request != nil {
+		s.request = s.newRequest()
+		vm.Set("request", s.request)
+	}
+	if s.env.Response != nil {
+		vm.Set("response", s.newResponse())
+	}
+	vm.Set("vars", s.newVars())
+	vm.Set("crypto", s.newCrypto())
+	if s.env.FileAccess {
+		vm.Set("files", s.newFiles())
+	}
+}
+
+// throw raises err as a JavaScript Error from a Go callback.
+func (s *scriptAPI) throw(err error) {
+	obj, ctorErr := s.vm.New(s.vm.Get("Error"), s.vm.ToValue(err.Error()))
+	if ctorErr != nil {
+		panic(s.vm.NewGoError(err))
+	}
+	panic(obj)
+}
+
+// newRequest exposes method, url and body as plain properties, read back
+// after a pre script, and headers through get/set/add/remove/all. Pre
+// scripts can also call request.resolve() to see the request as it would
+// be sent with the variables known so far.
+func (s *scriptAPI) newRequest() *goja.Object {
+	vm := s.vm
+	req := s.env.Request
+	s.headers = req.Headers.Clone()
+
+	obj := vm.NewObject()
+	obj.Set("name", req.Name)
+	obj.Set("method", req.Method)
+	obj.Set("url", req.URL)
+	obj.Set("body", req.Body)
+	obj.Set("headers", s.newHeaders(&s.headers, s.env.Response == nil))
+	if s.env.Response == nil {
+		obj.Set("resolve", func(goja.FunctionCall) goja.Value {
+			s.readRequest()
+			resolved, _ := ResolveRequest(*req, *s.env.Opts)
+			out := vm.NewObject()
+			out.Set("method", resolved.Method)
+			out.Set("url", resolved.URL)
+			out.Set("body", resolved.Body)
+			out.Set("headers", s.newHeaders(&resolved.Headers, false))
+			return out
+		})
+	}
+	return obj
+}
+
+// readRequest copies what a pre script changed back into the request.
+func (s *scriptAPI) readRequest() {
+	req := s.env.Request
+	req.Method = strings.ToUpper(s.request.Get("method").String())
+	req.URL = s.request.Get("url").String()
+	req.Body = s.request.Get("body").String()
+	req.Headers = s.headers.Clone()
+}
+
+func (s *scriptAPI) newHeaders(headers *models.Headers, writable bool) *goja.Object {
+	vm := s.vm
+	obj := vm.NewObject()
+	obj.Set("get", func(name string) goja.Value {
+		if !headers.Has(name) {
+			return goja.Null()
+		}
+		return vm.ToValue(headers.Get(name))
+	})
+	obj.Set("has", func(name string) bool { return headers.Has(name) })
+	obj.Set("all", func() []map[string]string {
+		out := make([]map[string]string, len(*headers))
+		for i, h := range *headers {
+			out[i] = map[string]string{"name": h.Name, "value": h.Value}
+		}
+		return out
+	})
+	if writable {
+		obj.Set("set", func(name, value string) { headers.Set(name, value) })
+		obj.Set("add", func(name, value string) { headers.Add(name, value) })
+		obj.Set("remove", func(name string) { headers.Del(name) })
+	}
+	return obj
+}
+
+func (s *scriptAPI) newResponse() *goja.Object {
+	vm := s.vm
+	resp := s.env.Response
+	obj := vm.NewObject()
+	obj.Set("status", resp.Status)
+	obj.Set("statusText", resp.StatusText)
+	obj.Set("body", resp.Body)
+	obj.Set("time", resp.Time)
+	obj.Set("size", resp.Size)
+	headers := resp.Headers.Clone()
+	obj.Set("headers", s.newHeaders(&headers, false))
+	obj.Set("json", func() goja.Value {
+		var v any
+		if err := json.Unmarshal([]byte(resp.Body), &v); err != nil {
+			s.throw(fmt.Errorf("body is not valid JSON: %w", err))
+		}
+		return vm.ToValue(v)
+	})
+	return obj
+}
+
+// newVars reads variables through the usual precedence, including paths
+// such as `user.id` and response references, and writes run variables.
+func (s *scriptAPI) newVars() *goja.Object {
+	vm := s.vm
+	obj := vm.NewObject()
+	obj.Set("get", func(name string) goja.Value {
+		val, ok := newResolver(*s.env.Opts).lookup(name)
+		if !ok {
+			return goja.Undefined()
+		}
+		return vm.ToValue(val)
+	})
+	obj.Set("set", func(name string, value goja.Value) {
+		val := s.stringify(value)
+		if s.env.Opts.Script == nil {
+			s.env.Opts.Script = map[string]string{}
+		}
+		s.env.Opts.Script[name] = val
+		if s.env.Vars != nil {
+			s.env.Vars[name] = val
+		}
+	})
+	obj.Set("resolve", func(text string) string {
+		return ResolveVariables(text, *s.env.Opts)
+	})
+	return obj
+}
+
+// newCrypto offers hashing, HMAC and base64. Digests are hex-encoded
+// unless "base64" is given as the last argument.
+func (s *scriptAPI) newCrypto() *goja.Object {
+	vm := s.vm
+	encode := func(sum []byte, encoding string) string {
+		if encoding == "base64" {
+			return base64.StdEncoding.EncodeToString(sum)
+		}
+		return hex.EncodeToString(sum)
+	}
+	hasher := func(alg string) func() hash.Hash {
+		switch strings.ToLower(strings.ReplaceAll(alg, "-", "")) {
+		case "md5":
+			return md5.New
+		case "sha1":
+			return sha1.New
+		case "sha256":
+			return sha256.New
+		case "sha512":
+			return sha512.New
+		}
+		s.throw(fmt.Errorf("unknown hash algorithm %q", alg))
+		return nil
+	}
+
+	obj := vm.NewObject()
+	obj.Set("hash", func(alg, data, encoding string) string {
+		h := hasher(alg)()
+		h.Write([]byte(data))
+		return encode(h.Sum(nil), encoding)
+	})
+	obj.Set("hmac", func(alg, key, data, encoding string) string {
+		h := hmac.New(hasher(alg), []byte(key))
+		h.Write([]byte(data))
+		return encode(h.Sum(nil), encoding)
+	})
+	obj.Set("base64", func(data string) string {
+		return base64.StdEncoding.EncodeToString([]byte(data))
+	})
+	obj.Set("base64Decode", func(data string) string {
+		out, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			s.throw(err)
+		}
+		return string(out)
+	})
+	obj.Set("uuid", func() string {
+		id, err := newUUID()
+		if err != nil {
+			s.throw(err)
+		}
+		return id
+	})
+	return obj
+}
+
+// newFiles gives read-only access to files inside the project. Symlinks
+// are followed before the check, so a link cannot point out of it.
+func (s *scriptAPI) newFiles() *goja.Object {
+	vm := s.vm
+	obj := vm.NewObject()
+	obj.Set("read", func(path string) string {
+		full := filepath.Join(s.env.ProjectPath, path)
+		if !isInside(s.env.ProjectPath, full) {
+			s.throw(fmt.Errorf("%s is outside the project", path))
+		}
+		root, err := filepath.EvalSymlinks(s.env.ProjectPath)
+		if err != nil {
+			s.throw(err)
+		}
+		if full, err = filepath.EvalSymlinks(full); err != nil {
+			s.throw(err)
+		}
+		if !isInside(root, full) {
+			s.throw(fmt.Errorf("%s is outside the project", path))
+		}
+		data, err := os.ReadFile(full)
+		if err != nil {
+			s.throw(err)
+		}
+		return string(data)
+	})
+	return obj
+}
+
+// isInside reports whether path is dir or below it.
+func isInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// stringify turns a script value into a variable value: strings as-is,
+// anything else as JSON.
+func (s *scriptAPI) stringify(v goja.Value) string {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return ""
+	}
+	if str, ok := v.Export().(string); ok {
+		return str
+	}
+	data, err := json.Marshal(v.Export())
+	if err != nil {
+		return v.String()
+	}
+	return string(data)
+}
+
+// display renders a console argument: objects as JSON, the rest as text.
+func (s *scriptAPI) display(v goja.Value) string {
+	if obj, ok := v.(*goja.Object); ok && obj.ClassName() != "Function" {
+		if data, err := json.Marshal(obj.Export()); err == nil {
+			return string(data)
+		}
+	}
+	return v.String()
+}
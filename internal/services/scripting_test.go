@@ -0,0 +1,147 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTestScript runs code as a pre script of a request to /.
+func runTestScript(t *testing.T, code string, env ScriptEnv) (models.ScriptResult, error) {
+	t.Helper()
+	if env.Opts == nil {
+		env.Opts = &ResolveOptions{}
+	}
+	if env.Request == nil {
+		env.Request = &models.ParsedHttpRequest{Method: "GET", URL: "/"}
+	}
+	results, err := RunScripts([]models.Script{{Phase: ScriptPre, Code: code}}, ScriptPre, env)
+	if len(results) != 1 {
+		t.Fatalf("got %d results", len(results))
+	}
+	return results[0], err
+}
+
+func TestScriptFilesStayInProject(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("outside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "data.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(project, "link.txt")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(project, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(project, "data.txt"), filepath.Join(project, "alias.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path    string
+		want    string
+		wantErr string
+	}{
+		{path: "data.txt", want: "inside"},
+		{path: "alias.txt", want: "inside"},
+		{path: "../" + filepath.Base(outside) + "/secret.txt", wantErr: "is outside the project"},
+		{path: "link.txt", wantErr: "link.txt is outside the project"},
+		{path: "dir/secret.txt", wantErr: "dir/secret.txt is outside the project"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			vars := map[string]string{}
+			_, err := runTestScript(t, `vars.set("got", files.read("`+tt.path+`"))`,
+				ScriptEnv{Vars: vars, ProjectPath: project, FileAccess: true})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if vars["got"] != tt.want {
+				t.Errorf("read %q, want %q", vars["got"], tt.want)
+			}
+		})
+	}
+
+	if _, err := runTestScript(t, `files.read("data.txt")`, ScriptEnv{ProjectPath: project}); err == nil || !strings.Contains(err.Error(), "files is not defined") {
+		t.Errorf("without file access: error = %v", err)
+	}
+}
+
+func TestScriptTimeout(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "busy loop", code: "for (;;) {}"},
+		{name: "blocking callback", code: `vars.set("early", "x"); vars.get("login.response.body.$.token")`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &ResolveOptions{Responses: func(string) (models.HttpResponse, error) {
+				<-block
+				return models.HttpResponse{Body: `{"token": "late"}`}, nil
+			}}
+			vars := map[string]string{}
+			start := time.Now()
+			result, err := runTestScript(t, `console.log("started"); `+tt.code, ScriptEnv{Opts: opts, Vars: vars, Timeout: 50 * time.Millisecond})
+			if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+				t.Fatalf("error = %v, want a timeout", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("returned after %s", elapsed)
+			}
+			// Its logs are kept, what it set before timing out is dropped
+			if len(result.Logs) != 1 || result.Logs[0] != "started" {
+				t.Errorf("logs = %q", result.Logs)
+			}
+			if len(vars) != 0 || opts.Script != nil {
+				t.Errorf("vars = %v, script values = %v", vars, opts.Script)
+			}
+		})
+	}
+}
+
+func TestScriptVarsPrecedence(t *testing.T) {
+	opts := &ResolveOptions{
+		Env:     models.EnvVariables{"a": "env", "b": "env", "c": "env"},
+		Runtime: map[string]string{"b": "runtime"},
+		Data:    map[string]string{"c": "data"},
+		Sets:    map[string]string{"d": "set"},
+	}
+	vars := map[string]string{}
+	req := &models.ParsedHttpRequest{Method: "GET", URL: "/{{a}}/{{b}}/{{c}}/{{d}}"}
+	code := `
+		vars.set("a", "script"); vars.set("b", "script"); vars.set("c", "script"); vars.set("d", "script")
+		console.log(vars.get("a"), vars.get("d"), request.resolve().url)
+	`
+	result, err := runTestScript(t, code, ScriptEnv{Opts: opts, Vars: vars, Request: req})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Script values win over data, runtime and env values, not over --set
+	if want := "script set /script/script/script/set"; len(result.Logs) != 1 || result.Logs[0] != want {
+		t.Errorf("logs = %q, want %q", result.Logs, want)
+	}
+	resolved, _ := ResolveRequest(*req, *opts)
+	if resolved.URL != "/script/script/script/set" {
+		t.Errorf("url after the script = %q", resolved.URL)
+	}
+	if len(vars) != 4 || vars["d"] != "script" {
+		t.Errorf("run vars = %v", vars)
+	}
+}
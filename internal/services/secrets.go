@@ -0,0 +1,485 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Secrets live next to the env files in `<env>.secrets.enc`: a JSON map of
+// variables encrypted with AES-256-GCM. The key is derived from a
+// passphrase with PBKDF2-SHA256, or from the contents of a key file.
+// Unlocked secrets are kept in memory only and merged by LoadEnv.
+
+const secretsExt = ".secrets.enc"
+
+const (
+	secretsKDFPassphrase = "pbkdf2-sha256"
+	secretsKDFKeyFile    = "keyfile"
+	secretsIterations    = 600000
+)
+
+// secretsAAD binds ciphertexts to this file format.
+var secretsAAD = []byte("carmelia-secrets-v1")
+
+var ErrSecretsLocked = errors.New("secrets are locked")
+
+type secretsEnvelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       string `json:"salt"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// unlockedSecrets is an unlocked secrets file: its key material, so it can
+// be written back, and its values.
+type unlockedSecrets struct {
+	kdf        string
+	iterations int
+	salt       []byte
+	key        []byte
+	values     map[string]string
+}
+
+var (
+	secretsMu      sync.Mutex
+	secretsKeyring = map[string]*unlockedSecrets{}
+)
+
+func secretsKey(projectPath, envName string) string {
+	return filepath.Clean(projectPath) + "\x00" + envName
+}
+
+func secretsPath(projectPath, envName string) string {
+	return filepath.Join(GetEnvsDir(projectPath), envName+secretsExt)
+}
+
+// UnlockSecrets decrypts an env's secrets file and keeps it unlocked for the
+// lifetime of the process. When the env has no secrets file yet, the
+// credential becomes the one used to create it on the first save.
+func UnlockSecrets(projectPath, envName string, cred models.SecretsCredential) error {
+	data, err := os.ReadFile(secretsPath(projectPath, envName))
+	if os.IsNotExist(err) {
+		unlocked, err := newUnlockedSecrets(cred)
+		if err != nil {
+			return err
+		}
+		storeUnlocked(projectPath, envName, unlocked)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read secrets: %w", err)
+	}
+
+	var env secretsEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return fmt.Errorf("failed to parse secrets file: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(env.Salt)
+	if err != nil {
+		return fmt.Errorf("invalid secrets file: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+	if err != nil {
+		return fmt.Errorf("invalid secrets file: %w", err)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(env.Ciphertext)
+	if err != nil {
+		return fmt.Errorf("invalid secrets file: %w", err)
+	}
+
+	unlocked := &unlockedSecrets{kdf: env.KDF, iterations: env.Iterations, salt: salt}
+	if unlocked.key, err = deriveSecretsKey(cred, env.KDF, salt, env.Iterations); err != nil {
+		return err
+	}
+	gcm, err := newSecretsGCM(unlocked.key)
+	if err != nil {
+		return err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return fmt.Errorf("invalid secrets file: bad nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, secretsAAD)
+	if err != nil {
+		return fmt.Errorf("wrong passphrase or key file")
+	}
+	if err := json.Unmarshal(plaintext, &unlocked.values); err != nil {
+		return fmt.Errorf("invalid secrets content: %w", err)
+	}
+	if unlocked.values == nil {
+		unlocked.values = map[string]string{}
+	}
+
+	storeUnlocked(projectPath, envName, unlocked)
+	return nil
+}
+
+// LockSecrets forgets the key and values of an env's secrets.
+func LockSecrets(projectPath, envName string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	delete(secretsKeyring, secretsKey(projectPath, envName))
+}
+
+// GetSecrets returns the values of an unlocked secrets file.
+func GetSecrets(projectPath, envName string) (map[string]string, error) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	unlocked, ok := secretsKeyring[secretsKey(projectPath, envName)]
+	if !ok {
+		return nil, ErrSecretsLocked
+	}
+	values := make(map[string]string, len(unlocked.values))
+	for k, v := range unlocked.values {
+		values[k] = v
+	}
+	return values, nil
+}
+
+// SaveSecrets replaces the values of an unlocked secrets file and writes it
+// encrypted.
+func SaveSecrets(projectPath, envName string, values map[string]string) error {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	unlocked, ok := secretsKeyring[secretsKey(projectPath, envName)]
+	if !ok {
+		return ErrSecretsLocked
+	}
+
+	next := *unlocked
+	next.values = make(map[string]string, len(values))
+	for k, v := range values {
+		next.values[k] = v
+	}
+	if err := writeSecrets(projectPath, envName, &next); err != nil {
+		return err
+	}
+	secretsKeyring[secretsKey(projectPath, envName)] = &next
+	return nil
+}
+
+// RotateSecrets re-encrypts an unlocked secrets file under a new credential.
+func RotateSecrets(projectPath, envName string, cred models.SecretsCredential) error {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	unlocked, ok := secretsKeyring[secretsKey(projectPath, envName)]
+	if !ok {
+		return ErrSecretsLocked
+	}
+
+	next, err := newUnlockedSecrets(cred)
+	if err != nil {
+		return err
+	}
+	next.values = unlocked.values
+	if err := writeSecrets(projectPath, envName, next); err != nil {
+		return err
+	}
+	secretsKeyring[secretsKey(projectPath, envName)] = next
+	return nil
+}
+
+// SecretsStatusFor reports whether an env has a secrets file and whether it
+// is unlocked.
+func SecretsStatusFor(projectPath, envName string) models.SecretsStatus {
+	_, err := os.Stat(secretsPath(projectPath, envName))
+	status := models.SecretsStatus{Exists: err == nil}
+
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if unlocked, ok := secretsKeyring[secretsKey(projectPath, envName)]; ok {
+		status.Unlocked = true
+		status.Keys = sortedKeys(unlocked.values)
+	}
+	return status
+}
+
+// unlockedSecretValues returns the unlocked secrets of an env, or nil.
+func unlockedSecretValues(projectPath, envName string) map[string]string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if unlocked, ok := secretsKeyring[secretsKey(projectPath, envName)]; ok {
+		return unlocked.values
+	}
+	return nil
+}
+
+// projectSecrets maps every unlocked secret value of a project to its
+// variable name, across all envs.
+func projectSecrets(projectPath string) map[string]string {
+	prefix := filepath.Clean(projectPath) + "\x00"
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	out := map[string]string{}
+	for key, unlocked := range secretsKeyring {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for name, value := range unlocked.values {
+			if value != "" {
+				out[value] = name
+			}
+		}
+	}
+	return out
+}
+
+// renameSecrets moves an env's secrets file and keyring entry.
+func renameSecrets(projectPath, oldName, newName string) error {
+	if err := os.Rename(secretsPath(projectPath, oldName), secretsPath(projectPath, newName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if unlocked, ok := secretsKeyring[secretsKey(projectPath, oldName)]; ok {
+		delete(secretsKeyring, secretsKey(projectPath, oldName))
+		secretsKeyring[secretsKey(projectPath, newName)] = unlocked
+	}
+	return nil
+}
+
+func storeUnlocked(projectPath, envName string, unlocked *unlockedSecrets) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	secretsKeyring[secretsKey(projectPath, envName)] = unlocked
+}
+
+func newUnlockedSecrets(cred models.SecretsCredential) (*unlockedSecrets, error) {
+	unlocked := &unlockedSecrets{
+		kdf:    secretsKDFPassphrase,
+		salt:   make([]byte, 16),
+		values: map[string]string{},
+	}
+	if cred.KeyFile != "" {
+		unlocked.kdf = secretsKDFKeyFile
+	} else {
+		unlocked.iterations = secretsIterations
+	}
+	if _, err := rand.Read(unlocked.salt); err != nil {
+		return nil, err
+	}
+	key, err := deriveSecretsKey(cred, unlocked.kdf, unlocked.salt, unlocked.iterations)
+	if err != nil {
+		return nil, err
+	}
+	unlocked.key = key
+	return unlocked, nil
+}
+
+func writeSecrets(projectPath, envName string, unlocked *unlockedSecrets) error {
+	plaintext, err := json.Marshal(unlocked.values)
+	if err != nil {
+		return err
+	}
+	gcm, err := newSecretsGCM(unlocked.key)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(secretsEnvelope{
+		Version:    1,
+		KDF:        unlocked.kdf,
+		Iterations: unlocked.iterations,
+		Salt:       base64.StdEncoding.EncodeToString(unlocked.salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plaintext, secretsAAD)),
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(GetEnvsDir(projectPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create envs dir: %w", err)
+	}
+	return os.WriteFile(secretsPath(projectPath, envName), append(data, '\n'), 0o644)
+}
+
+func newSecretsGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// deriveSecretsKey turns a credential into a 256-bit key for kdf.
+func deriveSecretsKey(cred models.SecretsCredential, kdf string, salt []byte, iterations int) ([]byte, error) {
+	switch kdf {
+	case secretsKDFPassphrase:
+		if cred.Passphrase == "" {
+			return nil, fmt.Errorf("a passphrase is required")
+		}
+		if iterations <= 0 {
+			return nil, fmt.Errorf("invalid secrets file: bad iteration count")
+		}
+		return pbkdf2.Key([]byte(cred.Passphrase), salt, iterations, 32, sha256.New), nil
+	case secretsKDFKeyFile:
+		if cred.KeyFile == "" {
+			return nil, fmt.Errorf("a key file is required")
+		}
+		content, err := os.ReadFile(cred.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		if len(content) < 16 {
+			return nil, fmt.Errorf("key file is too short")
+		}
+		h := sha256.New()
+		h.Write(salt)
+		h.Write(content)
+		return h.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("unsupported secrets key derivation %q", kdf)
+}
+
+// minRedactLength is the length under which secret values are not searched
+// for in free text such as response bodies: a secret like "1" or "true"
+// would mask unrelated text. Such values are still masked wherever the
+// resolver inserted them into a request.
+const minRedactLength = 6
+
+// redactSecrets replaces every secret value of at least minRedactLength
+// bytes found in text with mask(name). secrets maps values to names.
+func redactSecrets(text string, secrets map[string]string, mask func(name string) string) string {
+	if text == "" || len(secrets) == 0 {
+		return text
+	}
+	// Longest first, so a secret containing another is replaced whole
+	values := make([]string, 0, len(secrets))
+	for v := range secrets {
+		if len(v) >= minRedactLength {
+			values = append(values, v)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, mask(secrets[v]))
+	}
+	return text
+}
+
+// redactRunResult masks secrets in a result before it is stored. The
+// request is replaced by masked.request, where the resolver masked what it
+// inserted from secrets. Elsewhere the project's unlocked secret values, and
+// the text they were inserted as, are masked wherever they appear.
+func redactRunResult(projectPath string, result models.RunResult, masked maskedRequest) models.RunResult {
+	result.Request = masked.request
+	secrets := projectSecrets(projectPath)
+	for _, v := range masked.values {
+		secrets[v] = ""
+	}
+	if len(secrets) == 0 {
+		return result
+	}
+
+	mask := func(string) string { return maskedValue }
+	if result.Request.DynamicValues != nil {
+		dynamic := make(map[string]string, len(result.Request.DynamicValues))
+		for expr, v := range result.Request.DynamicValues {
+			dynamic[expr] = redactSecrets(v, secrets, mask)
+		}
+		result.Request.DynamicValues = dynamic
+	}
+	resp := result.Response
+	resp.Body = redactSecrets(resp.Body, secrets, mask)
+	headers := make(models.Headers, len(resp.Headers))
+	for i, h := range resp.Headers {
+		headers[i] = models.Header{Name: h.Name, Value: redactSecrets(h.Value, secrets, mask)}
+	}
+	resp.Headers = headers
+	if resp.Cookies != nil {
+		cookies := make([]models.CookieInfo, len(resp.Cookies))
+		for i, c := range resp.Cookies {
+			c.Value = redactSecrets(c.Value, secrets, mask)
+			cookies[i] = c
+		}
+		resp.Cookies = cookies
+	}
+	result.Response = resp
+	result.Error = redactSecrets(result.Error, secrets, mask)
+	if result.Assertions != nil {
+		assertions := make([]models.AssertionResult, len(result.Assertions))
+		for i, a := range result.Assertions {
+			a.Actual = redactSecrets(a.Actual, secrets, mask)
+			assertions[i] = a
+		}
+		result.Assertions = assertions
+	}
+	if result.Captures != nil {
+		captures := make([]models.CaptureResult, len(result.Captures))
+		for i, c := range result.Captures {
+			c.Value = redactSecrets(c.Value, secrets, mask)
+			c.Error = redactSecrets(c.Error, secrets, mask)
+			captures[i] = c
+		}
+		result.Captures = captures
+	}
+	if result.Scripts != nil {
+		scripts := make([]models.ScriptResult, len(result.Scripts))
+		for i, s := range result.Scripts {
+			if s.Logs != nil {
+				logs := make([]string, len(s.Logs))
+				for j, line := range s.Logs {
+					logs[j] = redactSecrets(line, secrets, mask)
+				}
+				s.Logs = logs
+			}
+			s.Error = redactSecrets(s.Error, secrets, mask)
+			scripts[i] = s
+		}
+		result.Scripts = scripts
+	}
+	return result
+}
+
+// RedactSessionState replaces secret values typed into open tabs with a
+// {{name}} reference to the secret, and drops local variable overrides
+// holding a secret.
+func RedactSessionState(state models.SessionState) models.SessionState {
+	if len(state.ProjectSessions) == 0 {
+		return state
+	}
+	sessions := make(map[string]models.ProjectSession, len(state.ProjectSessions))
+	for path, session := range state.ProjectSessions {
+		secrets := projectSecrets(path)
+		if len(secrets) > 0 {
+			tabs := make([]models.TabState, len(session.OpenTabs))
+			for i, tab := range session.OpenTabs {
+				tab.RawContent = redactSecrets(tab.RawContent, secrets, func(name string) string { return "{{" + name + "}}" })
+				if tab.RequestVars != nil {
+					vars := map[string]string{}
+					for k, v := range tab.RequestVars {
+						if _, secret := secrets[v]; !secret {
+							vars[k] = v
+						}
+					}
+					tab.RequestVars = vars
+				}
+				tabs[i] = tab
+			}
+			session.OpenTabs = tabs
+		}
+		sessions[path] = session
+	}
+	state.ProjectSessions = sessions
+	return state
+}
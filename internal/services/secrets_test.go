@@ -0,0 +1,292 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSecretsPassphraseRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { LockSecrets(dir, "dev") })
+
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{Passphrase: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	values := map[string]string{"api_key": "k-123", "password": "hunter2"}
+	if err := SaveSecrets(dir, "dev", values); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(secretsPath(dir, "dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Error("secrets file holds a plaintext value")
+	}
+
+	LockSecrets(dir, "dev")
+	if _, err := GetSecrets(dir, "dev"); !errors.Is(err, ErrSecretsLocked) {
+		t.Errorf("GetSecrets after lock error = %v, want ErrSecretsLocked", err)
+	}
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{Passphrase: "wrong"}); err == nil {
+		t.Error("unlocked with a wrong passphrase")
+	}
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{Passphrase: "correct horse"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetSecrets(dir, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, values) {
+		t.Errorf("secrets = %v, want %v", got, values)
+	}
+}
+
+func TestSecretsKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { LockSecrets(dir, "dev") })
+	key := writeKeyFile(t, dir, "dev.key", "0123456789abcdef0123456789abcdef")
+	other := writeKeyFile(t, dir, "other.key", "fedcba9876543210fedcba9876543210")
+	short := writeKeyFile(t, dir, "short.key", "too short")
+
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: short}); err == nil {
+		t.Error("accepted a short key file")
+	}
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSecrets(dir, "dev", map[string]string{"token": "t-1"}); err != nil {
+		t.Fatal(err)
+	}
+	status := SecretsStatusFor(dir, "dev")
+	if want := (models.SecretsStatus{Exists: true, Unlocked: true, Keys: []string{"token"}}); !reflect.DeepEqual(status, want) {
+		t.Errorf("status = %+v, want %+v", status, want)
+	}
+
+	tests := []struct {
+		name    string
+		cred    models.SecretsCredential
+		wantErr bool
+	}{
+		{"same key file", models.SecretsCredential{KeyFile: key}, false},
+		{"other key file", models.SecretsCredential{KeyFile: other}, true},
+		{"passphrase instead", models.SecretsCredential{Passphrase: "x"}, true},
+	}
+	for _, tt := range tests {
+		LockSecrets(dir, "dev")
+		if err := UnlockSecrets(dir, "dev", tt.cred); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	// Rotating re-encrypts the same values under the new key
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RotateSecrets(dir, "dev", models.SecretsCredential{KeyFile: other}); err != nil {
+		t.Fatal(err)
+	}
+	LockSecrets(dir, "dev")
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: key}); err == nil {
+		t.Error("old key still unlocks after rotation")
+	}
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: other}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := GetSecrets(dir, "dev"); got["token"] != "t-1" {
+		t.Errorf("secrets after rotation = %v", got)
+	}
+}
+
+func TestSecretsMergeIntoEnv(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { LockSecrets(dir, "dev") })
+	if err := os.MkdirAll(GetEnvsDir(dir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	envFile := filepath.Join(GetEnvsDir(dir), "dev.yaml")
+	if err := os.WriteFile(envFile, []byte("host: example.com\ntoken: fromfile\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	key := writeKeyFile(t, dir, "dev.key", "0123456789abcdef0123456789abcdef")
+	if err := UnlockSecrets(dir, "dev", models.SecretsCredential{KeyFile: key}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveSecrets(dir, "dev", map[string]string{"token": "s3cret", "password": "pw"}); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := LoadEnv(dir, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := models.EnvVariables{"host": "example.com", "token": "s3cret", "password": "pw"}
+	if !reflect.DeepEqual(vars, want) {
+		t.Errorf("LoadEnv = %v, want %v", vars, want)
+	}
+
+	// Saving what LoadEnv returned leaves secret values out of the file
+	vars["host"] = "api.example.com"
+	if err := SaveEnv(dir, "dev", vars); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), "host: api.example.com\ntoken: fromfile\n"; got != want {
+		t.Errorf("env file = %q, want %q", got, want)
+	}
+
+	// An edited value is no longer the secret's and is saved to the file
+	vars["token"] = "edited"
+	if err := SaveEnv(dir, "dev", vars); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(envFile)
+	if !strings.Contains(string(data), "token: edited") {
+		t.Errorf("edited value not saved: %q", data)
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	secrets := map[string]string{"abcdef": "inner", "abcdefgh": "outer", "true": "short"}
+	mask := func(name string) string { return "<" + name + ">" }
+	tests := []struct{ text, want string }{
+		{"", ""},
+		{"no secrets here", "no secrets here"},
+		{"key=abcdefgh", "key=<outer>"},
+		{"abcdef and abcdefgh", "<inner> and <outer>"},
+		{`{"ok": true}`, `{"ok": true}`},
+	}
+	for _, tt := range tests {
+		if got := redactSecrets(tt.text, secrets, mask); got != tt.want {
+			t.Errorf("redactSecrets(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestResolveRequestMasksSecrets(t *testing.T) {
+	secrets := map[string]string{"s3cr/et": "key", "hunter22": "token", "1": "flag", `pa"ss`: "password"}
+	env := models.EnvVariables{"key": "s3cr/et", "token": "hunter22", "flag": "1", "password": `pa"ss`, "auth": "Bearer {{token}}", "id": "7"}
+	tests := []struct {
+		name   string
+		req    models.ParsedHttpRequest
+		url    string
+		header string // empty when the header is not masked
+		body   string
+		values []string
+	}{
+		{
+			name:   "encoded in the URL",
+			req:    models.ParsedHttpRequest{URL: "https://example.com/{{id}}?key={{key}}"},
+			url:    "https://example.com/7?key=" + maskedValue,
+			values: []string{"s3cr%2Fet"},
+		},
+		{
+			name:   "filtered",
+			req:    models.ParsedHttpRequest{URL: "/a", Headers: models.Headers{{Name: "X-Token", Value: "{{token | base64}}.{{token | hex}}"}}},
+			url:    "/a",
+			header: maskedValue + "." + maskedValue,
+			values: []string{"68756e7465723232", "aHVudGVyMjI="},
+		},
+		{
+			name:   "escaped in a JSON string",
+			req:    models.ParsedHttpRequest{URL: "/a", Headers: models.Headers{{Name: "Content-Type", Value: "application/json"}}, Body: `{"password": "{{password}}"}`},
+			url:    "/a",
+			header: "application/json",
+			body:   `{"password": "` + maskedValue + `"}`,
+			values: []string{`pa\"ss`},
+		},
+		{
+			name:   "short secret only where it was inserted",
+			req:    models.ParsedHttpRequest{URL: "/items/1?page=1&flag={{flag}}"},
+			url:    "/items/1?page=1&flag=" + maskedValue,
+			values: []string{"1"},
+		},
+		{
+			name:   "inside another variable",
+			req:    models.ParsedHttpRequest{URL: "/a", Headers: models.Headers{{Name: "Authorization", Value: "{{auth}}"}}},
+			url:    "/a",
+			header: maskedValue,
+			values: []string{"Bearer hunter22", "hunter22"},
+		},
+		{
+			name: "signing key",
+			req:  models.ParsedHttpRequest{URL: "/a", Headers: models.Headers{{Name: "X-Sig", Value: "{{id | hmac_sha256 token | hex}}"}}},
+			url:  "/a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var masked maskedRequest
+			resolved, _ := resolveRequest(tt.req, ResolveOptions{Env: env, Secrets: secrets}, nil, &masked)
+			got := masked.request
+			if got.URL != tt.url {
+				t.Errorf("URL = %q, want %q", got.URL, tt.url)
+			}
+			if len(got.Headers) > 0 {
+				want := tt.header
+				if want == "" {
+					want = resolved.Headers[0].Value
+				}
+				if got.Headers[0].Value != want {
+					t.Errorf("header = %q, want %q", got.Headers[0].Value, want)
+				}
+			}
+			if got.Body != tt.body {
+				t.Errorf("body = %q, want %q", got.Body, tt.body)
+			}
+			if strings.Join(masked.values, " ") != strings.Join(tt.values, " ") {
+				t.Errorf("values = %q, want %q", masked.values, tt.values)
+			}
+		})
+	}
+}
+
+func TestRedactRunResult(t *testing.T) {
+	secrets := map[string]string{"hunter22": "token", "1": "flag"}
+	env := models.EnvVariables{"token": "hunter22", "flag": "1"}
+	req := models.ParsedHttpRequest{URL: "/a?flag={{flag}}", Headers: models.Headers{{Name: "X-Token", Value: "{{token | base64}}"}}}
+
+	var masked maskedRequest
+	resolved, _ := resolveRequest(req, ResolveOptions{Env: env, Secrets: secrets}, nil, &masked)
+	result := models.RunResult{
+		Request:  resolved,
+		Response: models.HttpResponse{Body: `{"echo": "aHVudGVyMjI=", "count": 1, "ok": true}`},
+		Captures: []models.CaptureResult{{Name: "t", Value: "aHVudGVyMjI="}},
+		Scripts:  []models.ScriptResult{{Phase: "post", Logs: []string{"token is aHVudGVyMjI="}}},
+	}
+	got := redactRunResult(t.TempDir(), result, masked)
+
+	if want := "/a?flag=" + maskedValue; got.Request.URL != want {
+		t.Errorf("URL = %q, want %q", got.Request.URL, want)
+	}
+	if want := `{"echo": "` + maskedValue + `", "count": 1, "ok": true}`; got.Response.Body != want {
+		t.Errorf("body = %q, want %q", got.Response.Body, want)
+	}
+	if got.Captures[0].Value != maskedValue {
+		t.Errorf("capture = %q", got.Captures[0].Value)
+	}
+	if want := "token is " + maskedValue; got.Scripts[0].Logs[0] != want {
+		t.Errorf("log = %q, want %q", got.Scripts[0].Logs[0], want)
+	}
+	if result.Captures[0].Value != "aHVudGVyMjI=" || result.Scripts[0].Logs[0] != "token is aHVudGVyMjI=" {
+		t.Error("redactRunResult modified its input")
+	}
+}
@@ -25,7 +25,7 @@ func SaveSessionState(state models.SessionState) error {
 		return err
 	}
 
-	data, err := json.MarshalIndent(state, "", "  ")
+	data, err := json.MarshalIndent(RedactSessionState(state), "", "  ")
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+)
+
+// textContext tracks where a placeholder sits in the literal text of a
+// template and encodes substituted values for that position. {{{name}}}
+// bypasses it and inserts the value raw.
+type textContext interface {
+	// advance consumes literal template text preceding a placeholder.
+	advance(literal string)
+	encode(value string) string
+	// escapedBy reports whether a value the filter escaped already fits
+	// the current position, so that it is not encoded twice.
+	escapedBy(filter string) bool
+}
+
+// rawContext inserts values unchanged. Used for headers and nested values.
+type rawContext struct{}
+
+func (rawContext) advance(string)             {}
+func (rawContext) encode(value string) string { return value }
+func (rawContext) escapedBy(string) bool      { return false }
+
+// URL positions, in the order they appear.
+const (
+	urlAuthority = iota
+	urlPath
+	urlQuery
+	urlFragment
+)
+
+// urlContext keeps values raw in the scheme and authority (so
+// {{base_url}} can carry a host and base path), path-escapes them in path
+// segments and the fragment, and query-escapes them in the query string.
+type urlContext struct {
+	zone int
+}
+
+func (c *urlContext) advance(literal string) {
+	for i := 0; i < len(literal); i++ {
+		switch literal[i] {
+		case ':':
+			if c.zone == urlAuthority && strings.HasPrefix(literal[i:], "://") {
+				i += 2
+			}
+		case '/':
+			if c.zone == urlAuthority {
+				c.zone = urlPath
+			}
+		case '?':
+			if c.zone < urlQuery {
+				c.zone = urlQuery
+			}
+		case '#':
+			c.zone = urlFragment
+		}
+	}
+}
+
+func (c *urlContext) encode(value string) string {
+	switch c.zone {
+	case urlPath, urlFragment:
+		return url.PathEscape(value)
+	case urlQuery:
+		return url.QueryEscape(value)
+	}
+	return value
+}
+
+func (c *urlContext) escapedBy(filter string) bool {
+	return filter == "urlencode" && c.zone != urlAuthority
+}
+
+// jsonContext escapes values placed inside a JSON string literal and keeps
+// them raw elsewhere, so `"count": {{n}}` still inserts a number.
+type jsonContext struct {
+	inString bool
+	escaped  bool
+}
+
+func (c *jsonContext) advance(literal string) {
+	for i := 0; i < len(literal); i++ {
+		ch := literal[i]
+		switch {
+		case !c.inString:
+			c.inString = ch == '"'
+		case c.escaped:
+			c.escaped = false
+		case ch == '\\':
+			c.escaped = true
+		case ch == '"':
+			c.inString = false
+		}
+	}
+}
+
+func (c *jsonContext) encode(value string) string {
+	if !c.inString {
+		return value
+	}
+	quoted := quoteJSON(value)
+	return quoted[1 : len(quoted)-1]
+}
+
+func (c *jsonContext) escapedBy(filter string) bool {
+	return filter == "jsonescape" && c.inString
+}
+
+// formContext query-escapes values in a form-encoded body.
+type formContext struct{}
+
+func (formContext) advance(string)               {}
+func (formContext) encode(value string) string   { return url.QueryEscape(value) }
+func (formContext) escapedBy(filter string) bool { return filter == "urlencode" }
+
+// bodyContext picks the context for a request body from its Content-Type,
+// falling back to JSON when there is none and the body looks like JSON.
+func bodyContext(contentType, body string) textContext {
+	ct := strings.ToLower(contentType)
+	switch {
+	case strings.Contains(ct, "json"):
+		return &jsonContext{}
+	case strings.Contains(ct, "application/x-www-form-urlencoded"):
+		return formContext{}
+	case ct == "":
+		trimmed := strings.TrimSpace(body)
+		if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			return &jsonContext{}
+		}
+	}
+	return rawContext{}
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// tlsEntry is the TLS configuration built for an env, with the transport
+// its requests share.
+type tlsEntry struct {
+	// version identifies the settings and the files they were built from
+	version   string
+	config    *tls.Config
+	transport *http.Transport
+}
+
+// tlsEntries holds one entry per project env, replaced when its settings
+// or files change; tlsTransports finds the transport of an entry's config.
+var (
+	tlsMu         sync.Mutex
+	tlsEntries    = map[string]*tlsEntry{}
+	tlsTransports = map[*tls.Config]*http.Transport{}
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// EnvTLSConfig returns the TLS configuration of an env from the project
+// config, or nil when the env has no TLS settings.
+func EnvTLSConfig(projectPath, envName string) (*tls.Config, error) {
+	config, err := LoadConfig(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	envKey := filepath.Clean(projectPath) + "\x00" + envName
+
+	tlsMu.Lock()
+	defer tlsMu.Unlock()
+	settings, ok := config.TLS[envName]
+	if !ok || envName == "" {
+		dropTLSEntry(envKey)
+		return nil, nil
+	}
+
+	settings = expandTLSSettings(projectPath, settings)
+	version, err := tlsVersionKey(settings)
+	if err != nil {
+		dropTLSEntry(envKey)
+		return nil, fmt.Errorf("TLS settings of %q: %w", envName, err)
+	}
+	if entry, ok := tlsEntries[envKey]; ok && entry.version == version {
+		return entry.config, nil
+	}
+
+	dropTLSEntry(envKey)
+	tlsConfig, err := BuildTLSConfig(settings)
+	if err != nil {
+		return nil, fmt.Errorf("TLS settings of %q: %w", envName, err)
+	}
+	entry := &tlsEntry{version: version, config: tlsConfig, transport: newTLSTransport(tlsConfig)}
+	tlsEntries[envKey] = entry
+	tlsTransports[tlsConfig] = entry.transport
+	return tlsConfig, nil
+}
+
+// dropTLSEntry forgets the entry of an env and closes its idle
+// connections. tlsMu must be held.
+func dropTLSEntry(envKey string) {
+	entry, ok := tlsEntries[envKey]
+	if !ok {
+		return
+	}
+	delete(tlsEntries, envKey)
+	delete(tlsTransports, entry.config)
+	entry.transport.CloseIdleConnections()
+}
+
+// expandTLSSettings reads ${VAR} references and makes paths absolute.
+func expandTLSSettings(projectPath string, s models.TLSConfig) models.TLSConfig {
+	abs := func(p string) string {
+		p = resolveSystemEnvVars(p)
+		if p != "" && !filepath.IsAbs(p) {
+			p = filepath.Join(projectPath, p)
+		}
+		return p
+	}
+
+	cas := make([]string, len(s.CAFiles))
+	for i, ca := range s.CAFiles {
+		cas[i] = abs(ca)
+	}
+	s.CAFiles = cas
+	s.ClientCert = abs(s.ClientCert)
+	s.ClientKey = abs(s.ClientKey)
+	s.PKCS12 = abs(s.PKCS12)
+	s.PKCS12Password = resolveSystemEnvVars(s.PKCS12Password)
+	s.ServerName = resolveSystemEnvVars(s.ServerName)
+	return s
+}
+
+// tlsVersionKey identifies settings together with the current version of
+// the files they read.
+func tlsVersionKey(s models.TLSConfig) (string, error) {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	key := string(data)
+	for _, path := range append(append([]string{}, s.CAFiles...), s.ClientCert, s.ClientKey, s.PKCS12) {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		key += fmt.Sprintf("|%d", info.ModTime().UnixNano())
+	}
+	return key, nil
+}
+
+// BuildTLSConfig loads the certificates of TLS settings whose paths are
+// already absolute.
+func BuildTLSConfig(s models.TLSConfig) (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         s.ServerName,
+		InsecureSkipVerify: s.InsecureSkipVerify,
+	}
+
+	if s.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(strings.ToLower(s.MinVersion), "tls")]
+		if !ok {
+			return nil, fmt.Errorf("unknown minVersion %q (use 1.0, 1.1, 1.2 or 1.3)", s.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(s.CAFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for _, path := range s.CAFiles {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no PEM certificates found in %s", filepath.Base(path))
+			}
+		}
+		config.RootCAs = pool
+	}
+
+	switch {
+	case s.PKCS12 != "" && (s.ClientCert != "" || s.ClientKey != ""):
+		return nil, fmt.Errorf("set either clientCert/clientKey or pkcs12, not both")
+	case s.PKCS12 != "":
+		cert, err := loadPKCS12(s.PKCS12, s.PKCS12Password)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case s.ClientCert != "":
+		keyFile := s.ClientKey
+		if keyFile == "" {
+			keyFile = s.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(s.ClientCert, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	case s.ClientKey != "":
+		return nil, fmt.Errorf("clientKey is set without clientCert")
+	}
+
+	return config, nil
+}
+
+// loadPKCS12 reads the client certificate, its chain and its key from a
+// PKCS#12 file.
+func loadPKCS12(path, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to read PKCS#12 file: %w", err)
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range chain {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+	}
+	return cert, nil
+}
+
+// tlsTransport returns the shared transport of a configuration returned by
+// EnvTLSConfig, or a new one for any other configuration.
+func tlsTransport(config *tls.Config) *http.Transport {
+	tlsMu.Lock()
+	t, ok := tlsTransports[config]
+	tlsMu.Unlock()
+	if ok {
+		return t
+	}
+	return newTLSTransport(config)
+}
+
+func newTLSTransport(config *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = config
+	return t
+}
+
+// tlsInfo describes the TLS connection of a response.
+func tlsInfo(state *tls.ConnectionState) *models.TLSInfo {
+	if state == nil {
+		return nil
+	}
+	return &models.TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+}
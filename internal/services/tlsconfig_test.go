@@ -0,0 +1,221 @@
+package services
+
+import (
+	"carmelia-desktop/internal/models"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newTestCert creates a self-signed certificate and its key.
+func newTestCert(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "carmelia test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// writePEM writes PEM blocks of the given type to a new file in dir.
+func writePEM(t *testing.T, dir, name, blockType string, ders ...[]byte) string {
+	t.Helper()
+	var data []byte
+	for _, der := range ders {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})...)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, key := newTestCert(t)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM(t, dir, "client.crt", "CERTIFICATE", cert.Raw)
+	keyFile := writePEM(t, dir, "client.key", "PRIVATE KEY", keyDER)
+	bundle := filepath.Join(dir, "bundle.pem")
+	bundleData, _ := os.ReadFile(certFile)
+	keyData, _ := os.ReadFile(keyFile)
+	if err := os.WriteFile(bundle, append(bundleData, keyData...), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	notPEM := filepath.Join(dir, "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		settings models.TLSConfig
+		check    func(*testing.T, *tls.Config)
+		wantErr  string
+	}{
+		{
+			name:     "server name and insecure",
+			settings: models.TLSConfig{ServerName: "api.internal", InsecureSkipVerify: true},
+			check: func(t *testing.T, c *tls.Config) {
+				if c.ServerName != "api.internal" || !c.InsecureSkipVerify {
+					t.Errorf("ServerName = %q, InsecureSkipVerify = %v", c.ServerName, c.InsecureSkipVerify)
+				}
+				if c.MinVersion != 0 || c.RootCAs != nil || len(c.Certificates) != 0 {
+					t.Errorf("unexpected defaults: %+v", c)
+				}
+			},
+		},
+		{
+			name:     "min version",
+			settings: models.TLSConfig{MinVersion: "1.2"},
+			check: func(t *testing.T, c *tls.Config) {
+				if c.MinVersion != tls.VersionTLS12 {
+					t.Errorf("MinVersion = %x", c.MinVersion)
+				}
+			},
+		},
+		{
+			name:     "min version with a TLS prefix",
+			settings: models.TLSConfig{MinVersion: "TLS1.3"},
+			check: func(t *testing.T, c *tls.Config) {
+				if c.MinVersion != tls.VersionTLS13 {
+					t.Errorf("MinVersion = %x", c.MinVersion)
+				}
+			},
+		},
+		{
+			name:     "unknown min version",
+			settings: models.TLSConfig{MinVersion: "1.4"},
+			wantErr:  `unknown minVersion "1.4"`,
+		},
+		{
+			name:     "CA files",
+			settings: models.TLSConfig{CAFiles: []string{certFile}},
+			check: func(t *testing.T, c *tls.Config) {
+				if c.RootCAs == nil {
+					t.Fatal("RootCAs not set")
+				}
+				if _, err := cert.Verify(x509.VerifyOptions{Roots: c.RootCAs}); err != nil {
+					t.Errorf("CA not trusted: %v", err)
+				}
+			},
+		},
+		{
+			name:     "CA file without certificates",
+			settings: models.TLSConfig{CAFiles: []string{notPEM}},
+			wantErr:  "no PEM certificates found in ca.txt",
+		},
+		{
+			name:     "client cert and key",
+			settings: models.TLSConfig{ClientCert: certFile, ClientKey: keyFile},
+			check:    checkClientCert(cert, 1),
+		},
+		{
+			name:     "key in the cert file",
+			settings: models.TLSConfig{ClientCert: bundle},
+			check:    checkClientCert(cert, 1),
+		},
+		{
+			name:     "key without cert",
+			settings: models.TLSConfig{ClientKey: keyFile},
+			wantErr:  "clientKey is set without clientCert",
+		},
+		{
+			name:     "both PEM and PKCS#12",
+			settings: models.TLSConfig{ClientCert: certFile, PKCS12: filepath.Join(dir, "client.p12")},
+			wantErr:  "set either clientCert/clientKey or pkcs12, not both",
+		},
+		{
+			name:     "PKCS#12 with AES encryption",
+			settings: models.TLSConfig{PKCS12: writePKCS12(t, dir, "modern.p12", pkcs12.Modern, cert, key, "pw"), PKCS12Password: "pw"},
+			check:    checkClientCert(cert, 2),
+		},
+		{
+			name:     "legacy PKCS#12",
+			settings: models.TLSConfig{PKCS12: writePKCS12(t, dir, "legacy.p12", pkcs12.LegacyDES, cert, key, "pw"), PKCS12Password: "pw"},
+			check:    checkClientCert(cert, 2),
+		},
+		{
+			name:     "PKCS#12 with a wrong password",
+			settings: models.TLSConfig{PKCS12: filepath.Join(dir, "modern.p12"), PKCS12Password: "nope"},
+			wantErr:  "failed to open modern.p12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := BuildTLSConfig(tt.settings)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, config)
+		})
+	}
+}
+
+// checkClientCert expects cert as the client certificate, followed by
+// chain-1 CA certificates.
+func checkClientCert(cert *x509.Certificate, chain int) func(*testing.T, *tls.Config) {
+	return func(t *testing.T, c *tls.Config) {
+		if len(c.Certificates) != 1 {
+			t.Fatalf("got %d certificates", len(c.Certificates))
+		}
+		got := c.Certificates[0]
+		if len(got.Certificate) != chain || string(got.Certificate[0]) != string(cert.Raw) {
+			t.Errorf("certificate chain has %d entries, want %d", len(got.Certificate), chain)
+		}
+		if got.PrivateKey == nil {
+			t.Error("private key not loaded")
+		}
+	}
+}
+
+func writePKCS12(t *testing.T, dir, name string, enc *pkcs12.Encoder, cert *x509.Certificate, key *ecdsa.PrivateKey, password string) string {
+	t.Helper()
+	data, err := enc.Encode(key, cert, []*x509.Certificate{cert}, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
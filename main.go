@@ -1,7 +1,9 @@
 package main
 
 import (
+	"carmelia-desktop/internal/cli"
 	"embed"
+	"os"
 
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -16,6 +18,10 @@ var assets embed.FS
 var icon []byte
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		os.Exit(cli.Run(os.Args[1:], os.Stdout, os.Stderr))
+	}
+
 	app := NewApp()
 
 	err := wails.Run(&options.App{